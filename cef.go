@@ -0,0 +1,116 @@
+package customsloglogger
+
+import (
+	"bytes"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// Here are the default header fields of the FormatCEF text logs
+const (
+	DEFAULT_CEF_VENDOR  = "darthyoh"
+	DEFAULT_CEF_PRODUCT = "custom-slog-logger"
+	DEFAULT_CEF_VERSION = "1.0"
+)
+
+// cefHeader(field, def) returns the CEF header field or its default value if empty
+func cefHeader(field, def string) string {
+	if field == "" {
+		return def
+	}
+	return field
+}
+
+// cefSeverity(level) returns the CEF severity (0 to 10) of a log level :
+// 1 for Debug, 3 for Info, 6 for Warn and 8 for Error, the levels in between
+// being mapped to the severities in between
+func cefSeverity(level slog.Level) int {
+	var severity int
+	switch {
+	case level < slog.LevelInfo:
+		severity = 1 + int(level-slog.LevelDebug)/2
+	case level < slog.LevelWarn:
+		severity = 3 + int(level-slog.LevelInfo)*3/4
+	case level < slog.LevelError:
+		severity = 6 + int(level-slog.LevelWarn)/2
+	default:
+		severity = 8 + int(level-slog.LevelError)/2
+	}
+	return min(max(severity, 0), 10)
+}
+
+// cefHeaderEscaper escapes the backslashes and pipes of the CEF header fields
+var cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+
+// cefExtensionEscaper escapes the backslashes, equal signs and line breaks of the CEF extension values
+var cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+
+// cefKey(key) returns the attribute key usable as a CEF extension key :
+// the characters other than letters, digits, dots, underscores and dashes are replaced by underscores
+func cefKey(key string) string {
+	return strings.Map(func(c rune) rune {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-' {
+			return c
+		}
+		return '_'
+	}, key)
+}
+
+// writeCEF(buf, hr) renders in buf the text log of the record as a Common Event Format line :
+// CEF:0|vendor|product|version|signatureID|name|severity|extension
+// with the level as signature id, the message as name and, as extension, the time (rt, in
+// milliseconds), the source, the component and the attributes with their dotted group keys
+func (m *CustomHandler) writeCEF(buf *bytes.Buffer, hr *handledRecord) {
+	buf.WriteString("CEF:0|")
+	for _, field := range []string{
+		cefHeader(m.Options.CEFVendor, DEFAULT_CEF_VENDOR),
+		cefHeader(m.Options.CEFProduct, DEFAULT_CEF_PRODUCT),
+		cefHeader(m.Options.CEFVersion, DEFAULT_CEF_VERSION),
+		levelName(hr.Level),
+		hr.Message,
+	} {
+		cefHeaderEscaper.WriteString(buf, field)
+		buf.WriteByte('|')
+	}
+	buf.WriteString(strconv.Itoa(cefSeverity(hr.Level)))
+	buf.WriteByte('|')
+
+	//the extension pairs are separated by spaces
+	first := true
+	writePair := func(key, value string) {
+		if !first {
+			buf.WriteByte(' ')
+		}
+		first = false
+		buf.WriteString(cefKey(key))
+		buf.WriteByte('=')
+		cefExtensionEscaper.WriteString(buf, value)
+	}
+	if !m.Options.NoTime {
+		writePair("rt", strconv.FormatInt(hr.Time.UnixMilli(), 10))
+	}
+	if hr.source != "" {
+		writePair("source", hr.source)
+	}
+	if m.Component != "" {
+		writePair(COMPONENT_KEY, m.Component)
+	}
+	var writeAttr func(prefix string, a slog.Attr)
+	writeAttr = func(prefix string, a slog.Attr) {
+		if a.Value.Kind() == slog.KindGroup {
+			if a.Key != "" {
+				prefix = prefix + a.Key + "."
+			}
+			for _, member := range a.Value.Group() {
+				writeAttr(prefix, member)
+			}
+			return
+		}
+		writePair(prefix+a.Key, m.textValue(a.Value))
+	}
+	for _, attr := range hr.attrs {
+		writeAttr(attr.prefix, attr.Attr)
+	}
+	buf.WriteByte('\n')
+}
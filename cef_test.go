@@ -0,0 +1,152 @@
+package customsloglogger
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// parseCEF(t, line) splits a CEF line into its header fields and extension pairs,
+// following the escaping rules of the CEF specification
+func parseCEF(t *testing.T, line string) ([]string, map[string]string) {
+	t.Helper()
+	if !strings.HasPrefix(line, "CEF:0|") {
+		t.Fatalf("expected a CEF:0 line, got %q", line)
+	}
+	line = strings.TrimPrefix(line, "CEF:0|")
+
+	//the 6 header fields following the version are separated by unescaped pipes, only \\ and \| being escaped
+	header := []string{}
+	field := strings.Builder{}
+	i := 0
+	for ; i < len(line) && len(header) < 6; i++ {
+		switch c := line[i]; {
+		case c == '\\' && i+1 < len(line) && (line[i+1] == '\\' || line[i+1] == '|'):
+			field.WriteByte(line[i+1])
+			i++
+		case c == '\\':
+			t.Fatalf("unescaped backslash in the header of %q", line)
+		case c == '|':
+			header = append(header, field.String())
+			field.Reset()
+		default:
+			field.WriteByte(c)
+		}
+	}
+	if len(header) != 6 {
+		t.Fatalf("expected 6 header fields, got %q", header)
+	}
+
+	//the extension pairs are key=value, the values escaping \\, \= and the line breaks,
+	//a pair ending at the space before the next key
+	extension := map[string]string{}
+	rest := line[i:]
+	for rest != "" {
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			t.Fatalf("expected a key=value pair, got %q", rest)
+		}
+		key := rest[:eq]
+		if strings.ContainsAny(key, " =\\|") {
+			t.Fatalf("invalid extension key %q", key)
+		}
+		value := strings.Builder{}
+		j := eq + 1
+		for ; j < len(rest); j++ {
+			c := rest[j]
+			if c == '\\' {
+				if j+1 >= len(rest) {
+					t.Fatalf("dangling backslash in %q", rest)
+				}
+				switch rest[j+1] {
+				case '\\', '=':
+					value.WriteByte(rest[j+1])
+				case 'n':
+					value.WriteByte('\n')
+				case 'r':
+					value.WriteByte('\r')
+				default:
+					t.Fatalf("invalid escape \\%c in %q", rest[j+1], rest)
+				}
+				j++
+				continue
+			}
+			if c == '=' {
+				t.Fatalf("unescaped equal sign in the value of %s : %q", key, rest)
+			}
+			if c == ' ' {
+				//the space ends the value only if a key= follows
+				next := rest[j+1:]
+				if eq := strings.IndexByte(next, '='); eq > 0 && !strings.ContainsAny(next[:eq], " \\") {
+					break
+				}
+			}
+			value.WriteByte(c)
+		}
+		extension[key] = value.String()
+		if j >= len(rest) {
+			break
+		}
+		rest = rest[j+1:]
+	}
+	return header, extension
+}
+
+func TestFormatCEF(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{
+		Format:     FormatCEF,
+		CEFVendor:  `Acme|Corp`,
+		CEFProduct: `Gate\Keeper`,
+		CEFVersion: "2.1",
+		NoTime:     true,
+	}).Named("auth").WithGroup("request")
+	logger.Warn("login failed | retry", "user", "bob=admin", "path", `C:\temp`, "note", "two words\nsecond line")
+
+	line := strings.TrimSuffix(buf.String(), "\n")
+	if strings.Contains(line, "\n") {
+		t.Fatalf("expected a single CEF line, got %q", buf.String())
+	}
+	for _, escaped := range []string{`Acme\|Corp`, `Gate\\Keeper`, `login failed \| retry`, `bob\=admin`, `C:\\temp`, `two words\nsecond line`} {
+		if !strings.Contains(line, escaped) {
+			t.Errorf("expected %q to be escaped as %s", line, escaped)
+		}
+	}
+
+	header, extension := parseCEF(t, line)
+	expectedHeader := []string{"Acme|Corp", `Gate\Keeper`, "2.1", "WARN", "login failed | retry", "6"}
+	for i, expected := range expectedHeader {
+		if header[i] != expected {
+			t.Errorf("expected the header field %d to be %q, got %q", i+1, expected, header[i])
+		}
+	}
+	for key, expected := range map[string]string{
+		"component":    "auth",
+		"request.user": "bob=admin",
+		"request.path": `C:\temp`,
+		"request.note": "two words\nsecond line",
+	} {
+		if extension[key] != expected {
+			t.Errorf("expected the extension %s=%q, got %q", key, expected, extension[key])
+		}
+	}
+	if _, ok := extension["rt"]; ok {
+		t.Errorf("expected no rt extension with NoTime, got %q", line)
+	}
+}
+
+func TestCEFSeverity(t *testing.T) {
+	for level, expected := range map[slog.Level]int{
+		slog.LevelDebug - 8: 0,
+		slog.LevelDebug:     1,
+		slog.LevelInfo:      3,
+		slog.LevelWarn:      6,
+		slog.LevelError:     8,
+		slog.LevelError + 8: 10,
+	} {
+		if got := cefSeverity(level); got != expected {
+			t.Errorf("expected the severity %d for %s, got %d", expected, level, got)
+		}
+	}
+}
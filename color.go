@@ -0,0 +1,148 @@
+package customsloglogger
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// ColorPalette defines the colors used for the colorized text logs.
+// Each color is an ANSI escape sequence (e.g. COLOR_RED, Color256(208) or TrueColor(255, 128, 0)).
+// An empty color uses the default color of the palette (see DefaultColorPalette())
+type ColorPalette struct {
+	//Debug is the color of the Debug level logs
+	Debug string
+	//Info is the color of the Info level logs
+	Info string
+	//Warn is the color of the Warn level logs
+	Warn string
+	//Error is the color of the Error level logs
+	Error string
+	//Panic is the color of the Panic level logs
+	Panic string
+	//Other is the color of the logs of the custom levels
+	Other string
+	//Muted is the color of the time and source of the logs
+	Muted string
+	//ErrorValue is the color of the values of the error attributes
+	//(errors, or attributes whose key is one of the ErrorKeys option)
+	ErrorValue string
+}
+
+// DefaultColorPalette() returns the palette used by default for colorized text logs
+func DefaultColorPalette() ColorPalette {
+	return ColorPalette{
+		Debug:      COLOR_DARKGRAY,
+		Info:       COLOR_BLUE,
+		Warn:       COLOR_YELLOW,
+		Error:      COLOR_RED,
+		Panic:      COLOR_MAGENTA,
+		Other:      COLOR_WHITE,
+		Muted:      COLOR_DARKGRAY,
+		ErrorValue: COLOR_RED,
+	}
+}
+
+// Color256(code) returns the ANSI escape sequence of a color of the 256-color palette
+func Color256(code uint8) string {
+	return fmt.Sprintf("\033[38;5;%dm", code)
+}
+
+// TrueColor(r, g, b) returns the ANSI escape sequence of a 24-bit color
+func TrueColor(r, g, b uint8) string {
+	return fmt.Sprintf("\033[38;2;%d;%d;%dm", r, g, b)
+}
+
+// ansiSequence matches one or more ANSI SGR escape sequences (e.g. "\033[1m\033[31m")
+var ansiSequence = regexp.MustCompile(`^(\x1b\[[0-9;]*m)+$`)
+
+// Validate() checks that every color of the palette is empty or a well-formed ANSI escape sequence
+func (p ColorPalette) Validate() error {
+	colors := []struct{ name, color string }{
+		{"Debug", p.Debug}, {"Info", p.Info}, {"Warn", p.Warn},
+		{"Error", p.Error}, {"Panic", p.Panic}, {"Other", p.Other}, {"Muted", p.Muted}, {"ErrorValue", p.ErrorValue},
+	}
+	errs := []error{}
+	for _, c := range colors {
+		if c.color != "" && !ansiSequence.MatchString(c.color) {
+			errs = append(errs, fmt.Errorf("color %s %q is not an ANSI escape sequence", c.name, c.color))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// paletteColor(color, defaultColor) returns the color of a palette if it is a
+// well-formed ANSI escape sequence, or the default color
+func paletteColor(color, defaultColor string) string {
+	if color == "" || !ansiSequence.MatchString(color) {
+		return defaultColor
+	}
+	return color
+}
+
+// levelColor(level) returns the color of the level logs
+func (p ColorPalette) levelColor(level slog.Level) string {
+	switch level {
+	case slog.LevelDebug:
+		return paletteColor(p.Debug, COLOR_DARKGRAY)
+	case slog.LevelInfo:
+		return paletteColor(p.Info, COLOR_BLUE)
+	case slog.LevelWarn:
+		return paletteColor(p.Warn, COLOR_YELLOW)
+	case slog.LevelError:
+		return paletteColor(p.Error, COLOR_RED)
+	case LevelPanic:
+		return paletteColor(p.Panic, COLOR_MAGENTA)
+	}
+	return paletteColor(p.Other, COLOR_WHITE)
+}
+
+// mutedColor() returns the color of the time and source of the logs
+func (p ColorPalette) mutedColor() string {
+	return paletteColor(p.Muted, COLOR_DARKGRAY)
+}
+
+// errorValueColor() returns the color of the values of the error attributes
+func (p ColorPalette) errorValueColor() string {
+	return paletteColor(p.ErrorValue, COLOR_RED)
+}
+
+// ValueColorRule colors the numeric values of an attribute above a threshold in colorized text logs,
+// e.g. {Key: "latency_ms", Threshold: 500, Color: COLOR_YELLOW}
+type ValueColorRule struct {
+	//Key is the key (case-insensitive) of the attribute
+	Key string
+	//Threshold is the value above which the value of the attribute is colorized
+	Threshold float64
+	//Color is the ANSI escape sequence of the color of the value
+	Color string
+}
+
+// valueColor(rules, a) returns the color of the numeric value of the attribute : the color of the rule
+// with the highest threshold exceeded by the value, or false if the value exceeds no threshold of its key
+func valueColor(rules []ValueColorRule, a slog.Attr) (string, bool) {
+	var value float64
+	switch a.Value.Kind() {
+	case slog.KindInt64:
+		value = float64(a.Value.Int64())
+	case slog.KindUint64:
+		value = float64(a.Value.Uint64())
+	case slog.KindFloat64:
+		value = a.Value.Float64()
+	default:
+		return "", false
+	}
+
+	var color string
+	found := false
+	var threshold float64
+	for _, rule := range rules {
+		if !strings.EqualFold(rule.Key, a.Key) || value <= rule.Threshold || (found && rule.Threshold <= threshold) {
+			continue
+		}
+		color, threshold, found = rule.Color, rule.Threshold, true
+	}
+	return color, found
+}
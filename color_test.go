@@ -0,0 +1,127 @@
+package customsloglogger
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestColorPalette(t *testing.T) {
+	orange := Color256(208)
+	buf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{
+		ColorizeLogs: true,
+		ColorPalette: ColorPalette{Warn: orange, Muted: TrueColor(10, 20, 30)},
+	})
+
+	logger.Warn("custom warn")
+	if !strings.HasPrefix(buf.String(), orange+"================WARN") {
+		t.Errorf("expected the Warn banner in the custom color, got %q", buf.String())
+	}
+	if !strings.Contains(buf.String(), "\033[38;2;10;20;30m\n") {
+		t.Errorf("expected the time in the custom truecolor, got %q", buf.String())
+	}
+
+	buf.Reset()
+	logger.Error("default error")
+	if !strings.HasPrefix(buf.String(), COLOR_RED+"===============ERROR") {
+		t.Errorf("expected the Error banner in the default color, got %q", buf.String())
+	}
+}
+
+func TestColorPaletteValidate(t *testing.T) {
+	valid := ColorPalette{Info: COLOR_BLUE, Warn: Color256(208), Error: "\033[1m\033[31m", Other: TrueColor(1, 2, 3)}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("unexpected error for a valid palette : %s", err)
+	}
+
+	invalid := ColorPalette{Warn: "orange", Error: "\033[31"}
+	err := invalid.Validate()
+	if err == nil {
+		t.Fatalf("expected an error for an invalid palette")
+	}
+	for _, name := range []string{"Warn", "Error"} {
+		if !strings.Contains(err.Error(), "color "+name) {
+			t.Errorf("expected an error for the %s color, got %q", name, err)
+		}
+	}
+
+	//malformed colors fall back to the default ones
+	if color := invalid.levelColor(slog.LevelWarn); color != COLOR_YELLOW {
+		t.Errorf("expected the default color for malformed colors, got %q", color)
+	}
+}
+
+func TestErrorValueColor(t *testing.T) {
+	magenta := Color256(201)
+	for _, format := range []TextFormat{FormatBanner, FormatCompact} {
+		buf := &bytes.Buffer{}
+		logger := NewCustomLogger(buf, &CustomHandlerOptions{
+			Format:       format,
+			ColorizeLogs: true,
+			ColorPalette: ColorPalette{ErrorValue: magenta},
+			ErrorKeys:    []string{"err", "failure"},
+		})
+		logger.Info("request", "err", "timeout", "Failure", "disk full", "cause", errors.New("refused"), "user", "bob")
+
+		output := buf.String()
+		for _, value := range []string{"timeout", "disk full", "refused"} {
+			if !strings.Contains(output, magenta+value) && !strings.Contains(output, magenta+`"`+value) {
+				t.Errorf("expected %q in the error value color, got %q", value, output)
+			}
+		}
+		if strings.Contains(output, magenta+"bob") {
+			t.Errorf("expected the other values not to be colorized, got %q", output)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact}).Info("request", "error", "timeout")
+	if !strings.HasSuffix(buf.String(), " error=timeout\n") {
+		t.Errorf("expected no color without colorization, got %q", buf.String())
+	}
+}
+
+func TestValueColorRules(t *testing.T) {
+	rules := []ValueColorRule{
+		{Key: "latency_ms", Threshold: 1000, Color: COLOR_RED},
+		{Key: "latency_ms", Threshold: 500, Color: COLOR_YELLOW},
+	}
+	for _, test := range []struct {
+		latency any
+		color   string
+	}{
+		{120, ""},
+		{500, ""},
+		{int64(750), COLOR_YELLOW},
+		{1000.5, COLOR_RED},
+		{uint64(2000), COLOR_RED},
+	} {
+		buf := &bytes.Buffer{}
+		NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, ColorizeLogs: true, ValueColorRules: rules}).
+			Info("request", "latency_ms", test.latency, "count", 5000)
+		output := buf.String()
+		for _, color := range []string{COLOR_RED, COLOR_YELLOW} {
+			colorized := strings.Contains(output, "latency_ms="+color)
+			if colorized != (color == test.color) {
+				t.Errorf("unexpected color of the latency %v : %q", test.latency, output)
+			}
+		}
+		if !strings.Contains(output, " count=5000") {
+			t.Errorf("expected the values without rule not to be colorized, got %q", output)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, ValueColorRules: rules}).Info("request", "latency_ms", 2000)
+	if !strings.HasSuffix(buf.String(), " latency_ms=2000\n") {
+		t.Errorf("expected no color without colorization, got %q", buf.String())
+	}
+
+	options := &CustomHandlerOptions{ValueColorRules: []ValueColorRule{{Key: "latency_ms", Threshold: 1, Color: "red"}}}
+	if err := options.Validate(); err == nil || !strings.Contains(err.Error(), "invalid ValueColorRules") {
+		t.Errorf("expected the malformed color to be rejected, got %v", err)
+	}
+}
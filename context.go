@@ -0,0 +1,33 @@
+package customsloglogger
+
+import (
+	"context"
+	"os"
+	"sync"
+)
+
+// loggerCtxKey is the context key of the logger stored by ToContext()
+type loggerCtxKey struct{}
+
+// defaultLogger is the logger returned by FromContext() when no logger is stored in the context :
+// a logger with the defaults of NewCustomLogger(), writing on os.Stderr
+var defaultLogger = sync.OnceValue(func() *CustomLogger {
+	return NewCustomLogger(os.Stderr, nil)
+})
+
+// ToContext(ctx, l) returns a copy of the context storing the logger,
+// e.g. a request-scoped logger with the request attributes already set
+func ToContext(ctx context.Context, l *CustomLogger) context.Context {
+	return context.WithValue(ctx, loggerCtxKey{}, l)
+}
+
+// FromContext(ctx) returns the logger stored in the context by ToContext(),
+// or a default logger (see NewCustomLogger()) writing on os.Stderr if there is none
+func FromContext(ctx context.Context) *CustomLogger {
+	if ctx != nil {
+		if l, ok := ctx.Value(loggerCtxKey{}).(*CustomLogger); ok && l != nil {
+			return l
+		}
+	}
+	return defaultLogger()
+}
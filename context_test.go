@@ -0,0 +1,34 @@
+package customsloglogger
+
+import (
+	"context"
+	"testing"
+)
+
+func TestContextLogger(t *testing.T) {
+	logger, memory := NewCaptureLogger(nil)
+	ctx := ToContext(context.Background(), logger.With("request_id", "abc"))
+
+	FromContext(ctx).Info("handled")
+
+	records := memory.Records()
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record logged with the context logger, got %d", len(records))
+	}
+	if v, ok := records[0].Attr("request_id"); !ok || v.String() != "abc" {
+		t.Errorf("expected request_id=abc, got %v (%t)", v, ok)
+	}
+}
+
+func TestContextLoggerDefault(t *testing.T) {
+	logger := FromContext(context.Background())
+	if logger == nil || logger.Handler() == nil {
+		t.Fatalf("expected a default logger")
+	}
+	if FromContext(context.Background()) != logger {
+		t.Errorf("expected the same default logger")
+	}
+	if FromContext(ToContext(context.Background(), nil)) != logger {
+		t.Errorf("expected the default logger for a nil stored logger")
+	}
+}
@@ -0,0 +1,49 @@
+package customsloglogger
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+)
+
+// DEFAULT_CORRELATION_ID_KEY is the default key of the correlation id (see GenerateCorrelationID option)
+const DEFAULT_CORRELATION_ID_KEY = "correlation_id"
+
+// correlationIDKey() returns the CorrelationIDKey option or its default value
+func (o *CustomHandlerOptions) correlationIDKey() string {
+	if o.CorrelationIDKey == "" {
+		return DEFAULT_CORRELATION_ID_KEY
+	}
+	return o.CorrelationIDKey
+}
+
+// newCorrelationID() returns a new random (version 4) UUID
+func newCorrelationID() string {
+	var uuid [16]byte
+	rand.Read(uuid[:])
+	uuid[6] = (uuid[6] & 0x0f) | 0x40
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16])
+}
+
+// generatedID() returns a new correlation id for a handler if the GenerateCorrelationID option is true
+func (o *CustomHandlerOptions) generatedID() string {
+	if !o.GenerateCorrelationID {
+		return ""
+	}
+	return newCorrelationID()
+}
+
+// correlationID(ctx) returns the correlation id of the record : the one of the context
+// (under the CorrelationIDKey, as a CtxKeyString or a string key), or the one generated for the handler
+func (m *CustomHandler) correlationID(ctx context.Context) string {
+	key := m.Options.correlationIDKey()
+	v := ctx.Value(CtxKeyString(key))
+	if v == nil {
+		v = ctx.Value(key)
+	}
+	if v != nil {
+		return fmt.Sprint(v)
+	}
+	return m.generatedID
+}
@@ -0,0 +1,54 @@
+package customsloglogger
+
+import (
+	"context"
+	"regexp"
+	"testing"
+)
+
+func TestCorrelationID(t *testing.T) {
+	logger, memory := NewCaptureLogger(&CustomHandlerOptions{GenerateCorrelationID: true})
+	derived := logger.Named("derived")
+
+	logger.Info("first")
+	logger.Info("second")
+	derived.Info("derived")
+	ctx := context.WithValue(context.Background(), CtxKeyString(DEFAULT_CORRELATION_ID_KEY), "from-context")
+	derived.InfoContext(ctx, "context")
+
+	records := memory.Records()
+	if len(records) != 4 {
+		t.Fatalf("expected 4 records, got %d", len(records))
+	}
+	ids := make([]string, 0, len(records))
+	for _, record := range records {
+		v, ok := record.Attr(DEFAULT_CORRELATION_ID_KEY)
+		if !ok {
+			t.Fatalf("expected a correlation id in %+v", record)
+		}
+		ids = append(ids, v.String())
+	}
+
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	if !uuid.MatchString(ids[0]) {
+		t.Errorf("expected a generated UUID, got %q", ids[0])
+	}
+	if ids[0] != ids[1] {
+		t.Errorf("expected the same id for the records of a logger, got %q and %q", ids[0], ids[1])
+	}
+	if ids[2] == ids[0] || !uuid.MatchString(ids[2]) {
+		t.Errorf("expected a new id for the derived logger, got %q", ids[2])
+	}
+	if ids[3] != "from-context" {
+		t.Errorf("expected the id of the context, got %q", ids[3])
+	}
+}
+
+func TestCorrelationIDKey(t *testing.T) {
+	logger, memory := NewCaptureLogger(&CustomHandlerOptions{GenerateCorrelationID: true, CorrelationIDKey: "trace"})
+	logger.InfoContext(context.WithValue(context.Background(), "trace", "abc"), "string key")
+
+	if v, ok := memory.Records()[0].Attr("trace"); !ok || v.String() != "abc" {
+		t.Errorf("expected the id of the context under the custom key, got %v (%t)", v, ok)
+	}
+}
@@ -0,0 +1,99 @@
+// Package customslogloggertest provides helpers to test the json logs of a customsloglogger.CustomLogger
+package customslogloggertest
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+)
+
+// CaptureServer is a local HTTP server storing the json logs it receives,
+// to be plugged into the JsonLogURL option of a logger under test :
+//
+//	server, url := customslogloggertest.NewCaptureServer()
+//	defer server.Close()
+//	logger := customsloglogger.NewCustomLogger(io.Discard, &customsloglogger.CustomHandlerOptions{JsonLogURL: url})
+//	logger.Info("hello")
+//	logger.Close(context.Background())
+//	payloads := server.Payloads()
+type CaptureServer struct {
+	server  *httptest.Server
+	mu      sync.Mutex
+	bodies  [][]byte
+	headers []http.Header
+}
+
+// NewCaptureServer() starts a CaptureServer and returns it with its URL.
+// The server must be closed with Close()
+func NewCaptureServer() (*CaptureServer, string) {
+	s := &CaptureServer{}
+	s.server = httptest.NewServer(http.HandlerFunc(s.capture))
+	return s, s.server.URL
+}
+
+// capture() stores the body of the request (decompressed if it is gzip encoded) and its headers
+func (s *CaptureServer) capture(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer zr.Close()
+		body = zr
+	}
+	content, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bodies = append(s.bodies, content)
+	s.headers = append(s.headers, r.Header.Clone())
+}
+
+// Bodies() returns the bodies received, in their order of arrival
+func (s *CaptureServer) Bodies() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.bodies)
+}
+
+// Headers() returns the headers of the requests received, in their order of arrival
+func (s *CaptureServer) Headers() []http.Header {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.headers)
+}
+
+// Payloads() returns the bodies received decoded as json objects, in their order of arrival.
+// The bodies which aren't json objects (e.g. MessagePack logs) are skipped : see Bodies()
+func (s *CaptureServer) Payloads() []map[string]any {
+	bodies := s.Bodies()
+	payloads := make([]map[string]any, 0, len(bodies))
+	for _, body := range bodies {
+		payload := map[string]any{}
+		if err := json.Unmarshal(body, &payload); err == nil {
+			payloads = append(payloads, payload)
+		}
+	}
+	return payloads
+}
+
+// Reset() forgets the bodies received so far
+func (s *CaptureServer) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bodies, s.headers = nil, nil
+}
+
+// Close() shuts the server down, waiting for the requests being received
+func (s *CaptureServer) Close() {
+	s.server.Close()
+}
@@ -0,0 +1,75 @@
+package customslogloggertest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	customsloglogger "github.com/darthyoh/custom-slog-logger"
+)
+
+func TestCaptureServer(t *testing.T) {
+	server, url := NewCaptureServer()
+	defer server.Close()
+
+	logger := customsloglogger.NewCustomLogger(io.Discard, &customsloglogger.CustomHandlerOptions{
+		JsonLogURL:  url,
+		JsonHeaders: http.Header{"Authorization": []string{"Bearer token"}},
+	})
+	logger.Info("first", "id", 1)
+	logger.Warn("second")
+	if err := logger.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected error closing the logger : %s", err)
+	}
+
+	payloads := server.Payloads()
+	if len(payloads) != 2 {
+		t.Fatalf("expected 2 payloads, got %d", len(payloads))
+	}
+	messages := []string{payloads[0]["msg"].(string), payloads[1]["msg"].(string)}
+	if !(messages[0] == "first" && messages[1] == "second") && !(messages[0] == "second" && messages[1] == "first") {
+		t.Errorf("unexpected payloads %v", payloads)
+	}
+	for _, headers := range server.Headers() {
+		if headers.Get("Authorization") != "Bearer token" {
+			t.Errorf("expected the headers of the requests, got %v", headers)
+		}
+	}
+
+	server.Reset()
+	if len(server.Bodies()) != 0 || len(server.Payloads()) != 0 {
+		t.Errorf("expected no body after Reset()")
+	}
+}
+
+func TestCaptureServerGzipAndInvalidBodies(t *testing.T) {
+	server, url := NewCaptureServer()
+	defer server.Close()
+
+	compressed := &bytes.Buffer{}
+	zw := gzip.NewWriter(compressed)
+	zw.Write([]byte(`{"msg":"compressed"}`))
+	zw.Close()
+	req, _ := http.NewRequest(http.MethodPost, url, compressed)
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unable to send the compressed body : %s", err)
+	}
+	resp.Body.Close()
+	if resp, err = http.Post(url, "text/plain", strings.NewReader("not json")); err != nil {
+		t.Fatalf("unable to send the invalid body : %s", err)
+	}
+	resp.Body.Close()
+
+	if bodies := server.Bodies(); len(bodies) != 2 || string(bodies[0]) != `{"msg":"compressed"}` || string(bodies[1]) != "not json" {
+		t.Errorf("expected the decompressed and the raw bodies, got %q", bodies)
+	}
+	if payloads := server.Payloads(); len(payloads) != 1 || payloads[0]["msg"] != "compressed" {
+		t.Errorf("expected only the json payload, got %v", payloads)
+	}
+}
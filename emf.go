@@ -0,0 +1,129 @@
+package customsloglogger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Here are the keys of the CloudWatch Embedded Metric Format (EMF) logs returned by EMFAttrs()
+const (
+	//EMF_KEY is the key of the EMF metadata, describing the metrics for CloudWatch
+	EMF_KEY = "_aws"
+	//EMF_ERROR_KEY is the key of the error reporting the invalid metrics, dropped from the EMF logs
+	EMF_ERROR_KEY = "emf_error"
+	//EMF_MAX_METRICS is the maximum number of metrics of an EMF log
+	EMF_MAX_METRICS = 100
+	//EMF_MAX_NAME_LENGTH is the maximum length of a namespace or a metric name
+	EMF_MAX_NAME_LENGTH = 255
+)
+
+// EMFUnits are the CloudWatch units of the metrics, a MetricDef without unit being in "None"
+var EMFUnits = []string{
+	"Seconds", "Microseconds", "Milliseconds",
+	"Bytes", "Kilobytes", "Megabytes", "Gigabytes", "Terabytes",
+	"Bits", "Kilobits", "Megabits", "Gigabits", "Terabits",
+	"Percent", "Count",
+	"Bytes/Second", "Kilobytes/Second", "Megabytes/Second", "Gigabytes/Second", "Terabytes/Second",
+	"Bits/Second", "Kilobits/Second", "Megabits/Second", "Gigabits/Second", "Terabits/Second",
+	"Count/Second", "None",
+}
+
+// MetricDef is a metric of an EMF log (see EMFAttrs())
+type MetricDef struct {
+	Name  string  //the name of the metric, and the key of its value in the log
+	Unit  string  //one of the EMFUnits (optional)
+	Value float64 //the value of the metric
+}
+
+// Validate() checks the name, the unit and the value of the metric
+func (d MetricDef) Validate() error {
+	if d.Name == "" || len(d.Name) > EMF_MAX_NAME_LENGTH {
+		return fmt.Errorf("metric %q : the name must be 1 to %d bytes long", d.Name, EMF_MAX_NAME_LENGTH)
+	}
+	if d.Name == EMF_KEY {
+		return fmt.Errorf("metric %q : the name is reserved", d.Name)
+	}
+	if d.Unit != "" && !slices.Contains(EMFUnits, d.Unit) {
+		return fmt.Errorf("metric %q : invalid unit %q", d.Name, d.Unit)
+	}
+	if math.IsNaN(d.Value) || math.IsInf(d.Value, 0) {
+		return fmt.Errorf("metric %q : invalid value %v", d.Name, d.Value)
+	}
+	return nil
+}
+
+// emfMetadata is the EMF_KEY object of the EMF logs
+type emfMetadata struct {
+	Timestamp         int64                `json:"Timestamp"`
+	CloudWatchMetrics []emfMetricDirective `json:"CloudWatchMetrics"`
+}
+
+// emfMetricDirective describes to CloudWatch the metrics of a namespace
+type emfMetricDirective struct {
+	Namespace  string      `json:"Namespace"`
+	Dimensions [][]string  `json:"Dimensions"`
+	Metrics    []emfMetric `json:"Metrics"`
+}
+
+// emfMetric is the definition of a metric in an emfMetricDirective
+type emfMetric struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit,omitempty"`
+}
+
+// EMFAttrs(namespace, metrics) returns the attributes making a json log a CloudWatch Embedded Metric Format log,
+// from which CloudWatch extracts the metrics of the namespace : the EMF_KEY metadata, and the metric values
+// as top-level fields. The invalid metrics (see MetricDef.Validate()), the duplicated ones and the ones beyond
+// EMF_MAX_METRICS are dropped and reported by an EMF_ERROR_KEY attribute, as an invalid namespace :
+//
+//	logger.Info("request handled", customsloglogger.EMFAttrs("MyApp", []customsloglogger.MetricDef{
+//		{Name: "Latency", Unit: "Milliseconds", Value: 42},
+//	}))
+//
+// The metrics being extracted from the top-level fields, they must not be nested (e.g. by the NestAttrsUnder option)
+func EMFAttrs(namespace string, metrics []MetricDef) slog.Attr {
+	if namespace == "" || len(namespace) > EMF_MAX_NAME_LENGTH {
+		return slog.String(EMF_ERROR_KEY, fmt.Sprintf("namespace %q : the namespace must be 1 to %d bytes long", namespace, EMF_MAX_NAME_LENGTH))
+	}
+
+	attrs := make([]any, 0, len(metrics)+2)
+	definitions := make([]emfMetric, 0, len(metrics))
+	errs := []error{}
+	for _, metric := range metrics {
+		if err := metric.Validate(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if slices.ContainsFunc(definitions, func(d emfMetric) bool { return d.Name == metric.Name }) {
+			errs = append(errs, fmt.Errorf("metric %q : duplicated metric", metric.Name))
+			continue
+		}
+		if len(definitions) == EMF_MAX_METRICS {
+			errs = append(errs, fmt.Errorf("metric %q : more than %d metrics", metric.Name, EMF_MAX_METRICS))
+			continue
+		}
+		definitions = append(definitions, emfMetric{Name: metric.Name, Unit: metric.Unit})
+		//the values are json numbers, as CloudWatch ignores the metrics with string values
+		attrs = append(attrs, slog.Any(metric.Name, json.Number(strconv.FormatFloat(metric.Value, 'g', -1, 64))))
+	}
+
+	if len(definitions) > 0 {
+		attrs = append(attrs, slog.Any(EMF_KEY, emfMetadata{
+			Timestamp: time.Now().UnixMilli(),
+			CloudWatchMetrics: []emfMetricDirective{
+				{Namespace: namespace, Dimensions: [][]string{}, Metrics: definitions},
+			},
+		}))
+	}
+	if err := errors.Join(errs...); err != nil {
+		attrs = append(attrs, slog.String(EMF_ERROR_KEY, err.Error()))
+	}
+	//a group without key, its attributes being inlined at the top level of the log
+	return slog.Group("", attrs...)
+}
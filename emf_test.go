@@ -0,0 +1,71 @@
+package customsloglogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEMFAttrs(t *testing.T) {
+	jsonBuf := &bytes.Buffer{}
+	logger := NewCustomLogger(io.Discard, &CustomHandlerOptions{JsonWriter: jsonBuf})
+	logger.Info("request handled", "path", "/users", EMFAttrs("MyApp", []MetricDef{
+		{Name: "Latency", Unit: "Milliseconds", Value: 42.5},
+		{Name: "Requests", Unit: "Count", Value: 1},
+		{Name: "Hits", Value: 3},
+		{Name: "Size", Unit: "Octets", Value: 12},
+		{Name: "Ratio", Unit: "Percent", Value: math.NaN()},
+		{Name: "", Unit: "Count", Value: 1},
+		{Name: "Latency", Unit: "Seconds", Value: 1},
+	}))
+
+	var payload map[string]interface{}
+	decoder := json.NewDecoder(jsonBuf)
+	decoder.UseNumber()
+	if err := decoder.Decode(&payload); err != nil {
+		t.Fatalf("invalid json log : %s", err)
+	}
+	for key, expected := range map[string]json.Number{"Latency": "42.5", "Requests": "1", "Hits": "3"} {
+		if payload[key] != expected {
+			t.Errorf("expected the metric value %s = %s as a json number, got %#v", key, expected, payload[key])
+		}
+	}
+	if payload["path"] != "/users" || payload["Size"] != nil || payload["Ratio"] != nil {
+		t.Errorf("unexpected json log %v", payload)
+	}
+
+	metadata, _ := payload[EMF_KEY].(map[string]interface{})
+	if timestamp, err := metadata["Timestamp"].(json.Number).Int64(); err != nil || timestamp <= 0 {
+		t.Errorf("expected a timestamp in milliseconds, got %v", metadata["Timestamp"])
+	}
+	expected := []interface{}{map[string]interface{}{
+		"Namespace":  "MyApp",
+		"Dimensions": []interface{}{},
+		"Metrics": []interface{}{
+			map[string]interface{}{"Name": "Latency", "Unit": "Milliseconds"},
+			map[string]interface{}{"Name": "Requests", "Unit": "Count"},
+			map[string]interface{}{"Name": "Hits"},
+		},
+	}}
+	if !reflect.DeepEqual(metadata["CloudWatchMetrics"], expected) {
+		t.Errorf("expected the CloudWatchMetrics %v, got %v", expected, metadata["CloudWatchMetrics"])
+	}
+
+	emfError, _ := payload[EMF_ERROR_KEY].(string)
+	for _, reported := range []string{`"Size" : invalid unit "Octets"`, `"Ratio" : invalid value NaN`, `"" : the name`, `"Latency" : duplicated metric`} {
+		if !strings.Contains(emfError, reported) {
+			t.Errorf("expected %q to be reported, got %q", reported, emfError)
+		}
+	}
+
+	if attr := EMFAttrs("", []MetricDef{{Name: "Hits", Value: 1}}); attr.Key != EMF_ERROR_KEY {
+		t.Errorf("expected an empty namespace to be reported, got %v", attr)
+	}
+	if err := (MetricDef{Name: EMF_KEY, Value: 1}).Validate(); err == nil {
+		t.Errorf("expected the %s metric name to be rejected", EMF_KEY)
+	}
+}
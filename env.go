@@ -0,0 +1,71 @@
+package customsloglogger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// Here are the environment variables read by NewCustomLoggerFromEnv(), with LOG_LEVEL_ENV
+const (
+	//LOG_JSON_URL_ENV is the JsonLogURL, the json logs being sent to it if it is set
+	LOG_JSON_URL_ENV = "LOG_JSON_URL"
+	//LOG_COLOR_ENV enables or disables the colors of the text logs (ColorizeLogs option), e.g. LOG_COLOR=false
+	LOG_COLOR_ENV = "LOG_COLOR"
+	//LOG_SOURCE_ENV enables or disables the source of the logs (AddSource option), e.g. LOG_SOURCE=false
+	LOG_SOURCE_ENV = "LOG_SOURCE"
+)
+
+// NewCustomLoggerFromEnv() creates a new CustomLogger writing its text logs on w, with the defaults
+// of NewCustomLogger() overridden by the environment variables, e.g. for a twelve-factor app :
+// LOG_JSON_URL (the json logs are sent to it if it is set), LOG_LEVEL (parsed with ParseLevel()),
+// LOG_COLOR and LOG_SOURCE (parsed with strconv.ParseBool()).
+// The unset variables keep the defaults, as the invalid ones, which are reported on os.Stderr
+func NewCustomLoggerFromEnv(w io.Writer) *CustomLogger {
+	options, err := OptionsFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "customsloglogger : %s\n", err)
+	}
+	return NewCustomLogger(w, options)
+}
+
+// OptionsFromEnv() returns the options of NewCustomLoggerFromEnv() : the defaults of NewCustomLogger()
+// overridden by the environment variables. The invalid variables keep the defaults
+// and are returned as an error, with the options
+func OptionsFromEnv() (*CustomHandlerOptions, error) {
+	options := defaultOptions()
+	errs := []error{}
+
+	if jsonURL := os.Getenv(LOG_JSON_URL_ENV); jsonURL != "" {
+		if err := validateURL(LOG_JSON_URL_ENV, jsonURL); err != nil {
+			errs = append(errs, fmt.Errorf("environment variable %w", err))
+		} else {
+			options.JsonLogURL = jsonURL
+		}
+	}
+
+	if err := options.SetMinimumLevelFromEnv(LOG_LEVEL_ENV); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, boolVar := range []struct {
+		envVar string
+		option *bool
+	}{{LOG_COLOR_ENV, &options.ColorizeLogs}, {LOG_SOURCE_ENV, &options.AddSource}} {
+		envVar, option := boolVar.envVar, boolVar.option
+		value := os.Getenv(envVar)
+		if value == "" {
+			continue
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("environment variable %s : invalid boolean %q", envVar, value))
+			continue
+		}
+		*option = enabled
+	}
+
+	return options, errors.Join(errs...)
+}
@@ -0,0 +1,66 @@
+package customsloglogger
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/darthyoh/custom-slog-logger/customslogloggertest"
+)
+
+func TestOptionsFromEnv(t *testing.T) {
+	for _, envVar := range []string{LOG_JSON_URL_ENV, LOG_LEVEL_ENV, LOG_COLOR_ENV, LOG_SOURCE_ENV} {
+		t.Setenv(envVar, "")
+	}
+	options, err := OptionsFromEnv()
+	if err != nil || options.JsonLogURL != "" || options.MinimumLevel != slog.LevelInfo || !options.ColorizeLogs || !options.AddSource {
+		t.Errorf("expected the defaults without variables, got %+v (%v)", options, err)
+	}
+
+	t.Setenv(LOG_JSON_URL_ENV, "https://logs.example.com/ingest")
+	t.Setenv(LOG_LEVEL_ENV, "debug")
+	t.Setenv(LOG_COLOR_ENV, "false")
+	t.Setenv(LOG_SOURCE_ENV, "0")
+	options, err = OptionsFromEnv()
+	if err != nil || options.JsonLogURL != "https://logs.example.com/ingest" || options.MinimumLevel != slog.LevelDebug || options.ColorizeLogs || options.AddSource {
+		t.Errorf("expected the options of the variables, got %+v (%v)", options, err)
+	}
+
+	t.Setenv(LOG_JSON_URL_ENV, "logs.example.com")
+	t.Setenv(LOG_LEVEL_ENV, "loud")
+	t.Setenv(LOG_COLOR_ENV, "maybe")
+	t.Setenv(LOG_SOURCE_ENV, "")
+	options, err = OptionsFromEnv()
+	if err == nil || options.JsonLogURL != "" || options.MinimumLevel != slog.LevelInfo || !options.ColorizeLogs || !options.AddSource {
+		t.Errorf("expected an error and the defaults for the invalid variables, got %+v (%v)", options, err)
+	}
+	for _, envVar := range []string{LOG_JSON_URL_ENV, LOG_LEVEL_ENV, LOG_COLOR_ENV} {
+		if err != nil && !strings.Contains(err.Error(), envVar) {
+			t.Errorf("expected the error to name %s, got %v", envVar, err)
+		}
+	}
+}
+
+func TestNewCustomLoggerFromEnv(t *testing.T) {
+	server, serverURL := customslogloggertest.NewCaptureServer()
+	defer server.Close()
+	t.Setenv(LOG_JSON_URL_ENV, serverURL)
+	t.Setenv(LOG_LEVEL_ENV, "warn")
+	t.Setenv(LOG_COLOR_ENV, "false")
+	t.Setenv(LOG_SOURCE_ENV, "false")
+
+	buf := &bytes.Buffer{}
+	logger := NewCustomLoggerFromEnv(buf)
+	logger.Info("filtered")
+	logger.Warn("from env")
+	logger.Sync()
+
+	if strings.Contains(buf.String(), "filtered") || !strings.Contains(buf.String(), "from env") || strings.Contains(buf.String(), "\033[") || strings.Contains(buf.String(), "@env_test.go") {
+		t.Errorf("expected an uncolorized warn log without source, got %q", buf.String())
+	}
+	payloads := server.Payloads()
+	if len(payloads) != 1 || payloads[0]["msg"] != "from env" {
+		t.Errorf("expected the json log sent to %s, got %v", LOG_JSON_URL_ENV, payloads)
+	}
+}
@@ -0,0 +1,66 @@
+package customsloglogger
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// messageFilter filters the records on their message,
+// with the compiled SuppressPatterns and ForcePatterns options
+type messageFilter struct {
+	suppress []*regexp.Regexp
+	force    []*regexp.Regexp
+}
+
+// compilePatterns(patterns) compiles the patterns, returning the valid ones
+// and an error joining the errors of the invalid ones
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	errs := []error{}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid pattern %q : %w", pattern, err))
+			continue
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, errors.Join(errs...)
+}
+
+// newMessageFilter(options) creates the message filter of the options (ignoring the invalid patterns),
+// or returns nil if no pattern is defined
+func newMessageFilter(options *CustomHandlerOptions) *messageFilter {
+	if len(options.SuppressPatterns) == 0 && len(options.ForcePatterns) == 0 {
+		return nil
+	}
+	suppress, _ := compilePatterns(options.SuppressPatterns)
+	force, _ := compilePatterns(options.ForcePatterns)
+	return &messageFilter{suppress: suppress, force: force}
+}
+
+// matchAny(patterns, msg) checks if the message matches one of the patterns
+func matchAny(patterns []*regexp.Regexp, msg string) bool {
+	for _, re := range patterns {
+		if re.MatchString(msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// suppressed(msg) checks if the message matches one of the SuppressPatterns
+func (f *messageFilter) suppressed(msg string) bool {
+	return f != nil && matchAny(f.suppress, msg)
+}
+
+// forced(msg) checks if the message matches one of the ForcePatterns
+func (f *messageFilter) forced(msg string) bool {
+	return f != nil && matchAny(f.force, msg)
+}
+
+// forcing() checks if some messages can be forced whatever their level
+func (f *messageFilter) forcing() bool {
+	return f != nil && len(f.force) != 0
+}
@@ -0,0 +1,42 @@
+package customsloglogger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMessagePatterns(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{
+		Format:           FormatCompact,
+		SuppressPatterns: []string{`^health check`},
+		ForcePatterns:    []string{`cache (hit|miss)`, `^health`},
+	})
+
+	logger.Info("health check ok")
+	logger.Error("health check failed")
+	logger.Info("request served")
+	logger.Debug("cache miss for key")
+	logger.Debug("other debug message")
+
+	output := buf.String()
+	for _, suppressed := range []string{"health check ok", "health check failed", "other debug message"} {
+		if strings.Contains(output, suppressed) {
+			t.Errorf("unexpected log %q :\n%s", suppressed, output)
+		}
+	}
+	for _, expected := range []string{"INFO", "request served", "DEBUG", "cache miss for key"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected %q in output :\n%s", expected, output)
+		}
+	}
+}
+
+func TestMessagePatternsValidation(t *testing.T) {
+	options := &CustomHandlerOptions{SuppressPatterns: []string{"("}, ForcePatterns: []string{"[a-"}}
+	err := options.Validate()
+	if err == nil || !strings.Contains(err.Error(), "invalid SuppressPatterns") || !strings.Contains(err.Error(), "invalid ForcePatterns") {
+		t.Errorf("expected invalid patterns errors, got %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package customsloglogger
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// GCP_SOURCE_LOCATION_KEY is the key of the source of the FormatGCP text logs,
+// indexed by Google Cloud Logging
+const GCP_SOURCE_LOCATION_KEY = "logging.googleapis.com/sourceLocation"
+
+// gcpFieldNames are the names of the envelope fields of the FormatGCP text logs
+var gcpFieldNames = JsonFieldNames{Time: "time", Level: "severity", Message: "message", Source: GCP_SOURCE_LOCATION_KEY}
+
+// gcpSeverity(level) returns the Google Cloud Logging severity of a log level :
+// DEBUG, INFO, WARNING, ERROR, or CRITICAL for LevelPanic and above
+func gcpSeverity(level slog.Level) string {
+	switch {
+	case level < slog.LevelInfo:
+		return "DEBUG"
+	case level < slog.LevelWarn:
+		return "INFO"
+	case level < slog.LevelError:
+		return "WARNING"
+	case level < LevelPanic:
+		return "ERROR"
+	}
+	return "CRITICAL"
+}
+
+// writeGCP(buf, hr) renders in buf the text log of the record as a json line structured for
+// Google Cloud Logging (e.g. collected on the standard output of Cloud Run) : the json log
+// with the severity, message and RFC 3339 time fields, and the source as a sourceLocation
+func (m *CustomHandler) writeGCP(buf *bytes.Buffer, hr *handledRecord) error {
+	data := m.payloadData(hr, m.jsonValue, gcpFieldNames)
+	data[gcpFieldNames.Level] = gcpSeverity(hr.Level)
+	if !m.Options.NoTime {
+		data[gcpFieldNames.Time] = hr.Time.Format(time.RFC3339Nano)
+	}
+	if hr.source != "" {
+		//the line is an int64, represented as a json string by Cloud Logging
+		data[gcpFieldNames.Source] = map[string]interface{}{
+			"file":     hr.frame.File,
+			"line":     strconv.Itoa(hr.frame.Line),
+			"function": hr.frame.Function,
+		}
+	}
+
+	jsonByte, err := m.Options.jsonMarshal()(data)
+	if err != nil {
+		return fmt.Errorf("unable to render gcp log : %w", err)
+	}
+	buf.Write(jsonByte)
+	buf.WriteByte('\n')
+	return nil
+}
@@ -0,0 +1,49 @@
+package customsloglogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatGCP(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatGCP, AddSource: true, MinimumLevel: slog.LevelDebug})
+	_, _, line, _ := runtime.Caller(0)
+	logger.Warn("disk almost full", "message", "attribute message", "usage", 91)
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &payload); err != nil {
+		t.Fatalf("invalid gcp log %q : %s", buf.String(), err)
+	}
+	if payload["severity"] != "WARNING" || payload["message"] != "disk almost full" || payload["attr_message"] != "attribute message" || payload["usage"] != 91.0 {
+		t.Errorf("unexpected gcp log %v", payload)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, fmt.Sprint(payload["time"])); err != nil {
+		t.Errorf("expected an RFC 3339 time, got %v", payload["time"])
+	}
+	source, _ := payload[GCP_SOURCE_LOCATION_KEY].(map[string]interface{})
+	if file, _ := source["file"].(string); filepath.Base(file) != "gcp_test.go" || source["line"] != fmt.Sprint(line+1) || !strings.HasSuffix(fmt.Sprint(source["function"]), ".TestFormatGCP") {
+		t.Errorf("unexpected source location %v", payload[GCP_SOURCE_LOCATION_KEY])
+	}
+	for _, key := range []string{"level", "msg", "source"} {
+		if _, ok := payload[key]; ok {
+			t.Errorf("unexpected key %q in the gcp log %v", key, payload)
+		}
+	}
+
+	for level, severity := range map[slog.Level]string{
+		slog.LevelDebug: "DEBUG", slog.LevelInfo: "INFO", slog.LevelWarn: "WARNING",
+		slog.LevelError: "ERROR", slog.LevelError + 2: "ERROR", LevelPanic: "CRITICAL",
+	} {
+		if gcpSeverity(level) != severity {
+			t.Errorf("expected the severity %s for %s, got %s", severity, levelName(level), gcpSeverity(level))
+		}
+	}
+}
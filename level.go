@@ -0,0 +1,57 @@
+package customsloglogger
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// LevelPanic is the level of the logs of the Panic() method, above slog.LevelError
+const LevelPanic = slog.LevelError + 4
+
+// levelName(level) returns the name of the level rendered in the logs :
+// "PANIC" for LevelPanic, else the slog name of the level (e.g. "INFO", "ERROR+2")
+func levelName(level slog.Level) string {
+	if level == LevelPanic {
+		return "PANIC"
+	}
+	return level.String()
+}
+
+// LOG_LEVEL_ENV is the environment variable read by SetMinimumLevelFromEnv() by default
+const LOG_LEVEL_ENV = "LOG_LEVEL"
+
+// ParseLevel(s) returns the level named by s : "debug", "info", "warn" or "error" (case-insensitive),
+// optionally followed by a numeric offset (e.g. "info+2", "error-1"), or "panic" for LevelPanic.
+// An error is returned for any other value
+func ParseLevel(s string) (slog.Level, error) {
+	if strings.EqualFold(strings.TrimSpace(s), "panic") {
+		return LevelPanic, nil
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("invalid log level %q : %w", s, err)
+	}
+	return level, nil
+}
+
+// SetMinimumLevelFromEnv() sets the MinimumLevel option from the envVar environment variable
+// (LOG_LEVEL_ENV if empty), parsed with ParseLevel(), e.g. LOG_LEVEL=debug.
+// The MinimumLevel is left unchanged if the variable is not set,
+// and an error is returned if its value is not a valid level
+func (o *CustomHandlerOptions) SetMinimumLevelFromEnv(envVar string) error {
+	if envVar == "" {
+		envVar = LOG_LEVEL_ENV
+	}
+	value, ok := os.LookupEnv(envVar)
+	if !ok || value == "" {
+		return nil
+	}
+	level, err := ParseLevel(value)
+	if err != nil {
+		return fmt.Errorf("environment variable %s : %w", envVar, err)
+	}
+	o.MinimumLevel = level
+	return nil
+}
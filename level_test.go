@@ -0,0 +1,50 @@
+package customsloglogger
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	for s, expected := range map[string]slog.Level{
+		"debug":    slog.LevelDebug,
+		"INFO":     slog.LevelInfo,
+		"Warn":     slog.LevelWarn,
+		"error":    slog.LevelError,
+		"info+2":   slog.LevelInfo + 2,
+		"ERROR-1":  slog.LevelError - 1,
+		" debug\n": slog.LevelDebug,
+	} {
+		level, err := ParseLevel(s)
+		if err != nil {
+			t.Errorf("unexpected error for %q : %s", s, err)
+		} else if level != expected {
+			t.Errorf("expected %s for %q, got %s", expected, s, level)
+		}
+	}
+
+	for _, s := range []string{"verbose", "", "info+", "3"} {
+		if _, err := ParseLevel(s); err == nil {
+			t.Errorf("expected an error for %q", s)
+		}
+	}
+}
+
+func TestSetMinimumLevelFromEnv(t *testing.T) {
+	options := &CustomHandlerOptions{MinimumLevel: slog.LevelInfo}
+
+	t.Setenv(LOG_LEVEL_ENV, "")
+	if err := options.SetMinimumLevelFromEnv(""); err != nil || options.MinimumLevel != slog.LevelInfo {
+		t.Errorf("expected the level unchanged without variable, got %s (%v)", options.MinimumLevel, err)
+	}
+
+	t.Setenv(LOG_LEVEL_ENV, "debug")
+	if err := options.SetMinimumLevelFromEnv(""); err != nil || options.MinimumLevel != slog.LevelDebug {
+		t.Errorf("expected the debug level, got %s (%v)", options.MinimumLevel, err)
+	}
+
+	t.Setenv("APP_LOG_LEVEL", "loud")
+	if err := options.SetMinimumLevelFromEnv("APP_LOG_LEVEL"); err == nil || options.MinimumLevel != slog.LevelDebug {
+		t.Errorf("expected an error and the level unchanged, got %s (%v)", options.MinimumLevel, err)
+	}
+}
@@ -12,25 +12,35 @@ package customsloglogger
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"net/http"
+	"net/url"
+	"os"
 	"path/filepath"
 	"runtime"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // CtxKeyString is the customsloglogger type defined for passing keys in context
 type CtxKeyString string
 
 // Here are the definitions of ASCII colors for the logger.
-// Theses colors will be used depending of the log level
+// Theses colors will be used depending of the log level,
+// unless other colors are defined in the ColorPalette option
 const (
 	COLOR_RESET    = "\033[0m"
 	COLOR_DARKGRAY = "\033[90m"
@@ -38,17 +48,72 @@ const (
 	COLOR_BLUE     = "\033[34m"
 	COLOR_YELLOW   = "\033[33m"
 	COLOR_WHITE    = "\033[97m"
+	COLOR_MAGENTA  = "\033[95m"
 )
 
-// colorize(colorCode, v) returns a colorized string of a string value.
-func colorize(colorCode string, v string, colorized bool) string {
+// REDACTED_VALUE is the value logged in place of the value of attributes
+// whose key is one of the RedactKeys option
+const REDACTED_VALUE = "***REDACTED***"
 
-	if !colorized {
-		return v
+// colorize(buf, colorCode, colorized, values...) writes the values in buf,
+// colorized with colorCode if colorized is true.
+func colorize(buf *bytes.Buffer, colorCode string, colorized bool, values ...string) {
+	if colorized {
+		buf.WriteString(colorCode)
 	}
-	return fmt.Sprintf("%s%s%s", colorCode, v, COLOR_RESET)
+	for _, v := range values {
+		buf.WriteString(v)
+	}
+	if colorized {
+		buf.WriteString(COLOR_RESET)
+	}
+}
+
+// bufferPool is a pool of *bytes.Buffer used to render the logs
+// without allocating a new buffer for each record
+var bufferPool = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+// newBuffer() gets an empty buffer from the pool
+func newBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
 }
 
+// freeBuffer(buf) puts back the buffer in the pool.
+// Too large buffers are not kept to avoid holding memory after a huge log
+func freeBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > 64<<10 {
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// TextFormat defines how the logs are rendered on the TextWriter
+type TextFormat int
+
+// Here are the available text formats
+const (
+	//FormatBanner renders each log between two separator lines,
+	//with the attributes on their own lines (default)
+	FormatBanner TextFormat = iota
+	//FormatCompact renders each log on a single line
+	FormatCompact
+	//FormatJSON renders each log as a single line json object, the same as the
+	//json logs sent to JsonLogURL (e.g. to be collected on the standard output of a container)
+	FormatJSON
+	//FormatCEF renders each log as a Common Event Format line (e.g. to be ingested by a SIEM),
+	//with the CEFVendor, CEFProduct and CEFVersion options as header fields
+	FormatCEF
+	//FormatGCP renders each log as a single line json object structured for Google Cloud Logging
+	//(e.g. collected on the standard output of Cloud Run), with severity, message and sourceLocation fields
+	FormatGCP
+)
+
 // CustomHandlerOptions defines the behavior of the log handling
 type CustomHandlerOptions struct {
 	//AddSource causes the handler to compute the source code position
@@ -62,8 +127,581 @@ type CustomHandlerOptions struct {
 	JsonLogURL string
 	//MinimumLevel defines the minimum level considered to log (text or json)
 	//If the slog.Record passed to the Handle() method has an inferior level to this one
-	//it will be ignored
+	//it will be ignored (unless the TextMinimumLevel or JsonMinimumLevel of a sink is lower)
 	MinimumLevel slog.Level
+	//RedactKeys is a list of attribute keys (case-insensitive) whose values
+	//must never be logged. The value of a matching attribute (additionnal,
+	//record or context attribute) is replaced by REDACTED_VALUE.
+	//A key can also be given with its group prefix (e.g. "request.password")
+	RedactKeys []string
+	//ReplaceAttr is called to rewrite each attribute (additionnal, record or context attribute)
+	//before it is logged, like the ReplaceAttr of slog.HandlerOptions.
+	//groups is the group path of the attribute.
+	//If ReplaceAttr returns a zero slog.Attr, the attribute is dropped
+	ReplaceAttr func(groups []string, a slog.Attr) slog.Attr
+	//Format is the format of the text logs. The default FormatBanner
+	//renders the logs between separator lines, FormatCompact renders them
+	//on a single line, FormatJSON renders them as json lines, FormatCEF as CEF lines
+	//and FormatGCP as json lines for Google Cloud Logging
+	Format TextFormat
+	//JsonGzip causes the json logs sent to JsonLogURL to be gzip compressed
+	//(with a "Content-Encoding: gzip" header) when they are at least JsonGzipMinSize bytes long
+	JsonGzip bool
+	//JsonGzipMinSize is the minimum size in bytes of a json log to be compressed,
+	//smaller logs being sent uncompressed. If 0, DEFAULT_JSON_GZIP_MIN_SIZE is used.
+	//A negative value compresses every json log
+	JsonGzipMinSize int
+	//LevelWriters maps log levels to the io.Writer the text logs of this level are written on
+	//(e.g. os.Stderr for slog.LevelError and slog.LevelWarn, os.Stdout for the others).
+	//The logs whose level is not in LevelWriters are written on the TextWriter of the handler
+	LevelWriters map[slog.Level]io.Writer
+	//ColorPalette defines the colors of the colorized text logs.
+	//Its empty or malformed colors are replaced by the default ones (see DefaultColorPalette())
+	ColorPalette ColorPalette
+	//StacktraceLevel is the minimum level (e.g. slog.LevelError) of the logs
+	//for which the stack trace is captured and added as a STACKTRACE_KEY attribute.
+	//If nil (default), no stack trace is captured
+	StacktraceLevel slog.Leveler
+	//SamplerInitial and SamplerThereafter enable the sampling of the logs :
+	//within each SamplerTick window, the SamplerInitial first logs with the same level
+	//and message are handled, then only every SamplerThereafter log (none if 0).
+	//Sampling is disabled if both are 0 (default)
+	SamplerInitial    int
+	SamplerThereafter int
+	//SamplerTick is the duration of the sampling windows. If 0, DEFAULT_SAMPLER_TICK is used
+	SamplerTick time.Duration
+	//SamplerLevels maps log levels to their own sampling settings, overriding SamplerInitial
+	//and SamplerThereafter for the logs of this level (e.g. sampling the debug logs only).
+	//A level mapped to zero settings isn't sampled
+	SamplerLevels map[slog.Level]SamplerSettings
+	//CtxExtractors are functions returning attributes to log from the context passed to Handle().
+	//Unlike CtxAttrsKeys, they can bridge any context key type (e.g. the unexported key types
+	//of middlewares) into attributes
+	CtxExtractors []CtxExtractor
+	//SuppressPatterns are regular expressions : the logs whose message matches
+	//one of them are never handled, whatever their level (e.g. "^health check")
+	SuppressPatterns []string
+	//ForcePatterns are regular expressions : the logs whose message matches one of them
+	//are handled even if their level is under MinimumLevel (unless they are suppressed)
+	ForcePatterns []string
+	//JsonWriter is an optional io.Writer (e.g. a file tailed by a log shipper) on which
+	//the json logs are written as newline-delimited json, independently of the JsonLogURL option.
+	//Each record is then written both on the TextWriter as text and on the JsonWriter as json,
+	//unless it is logged by a TextOnly or JsonOnly method
+	JsonWriter io.Writer
+	//JsonTimeout is the duration after which the logging doesn't wait anymore for the
+	//sending of the json log to JsonLogURL. If 0, DEFAULT_JSON_TIMEOUT is used
+	JsonTimeout time.Duration
+	//JsonFieldNames remaps the keys of the envelope fields (time, level, message and source)
+	//of the json logs, e.g. to "@timestamp", "log.level" and "message".
+	//An attribute whose key collides with an envelope field is renamed with an "attr_" prefix
+	JsonFieldNames JsonFieldNames
+	//JsonMethod is the HTTP method used to send the json logs to JsonLogURL :
+	//POST (default), PUT or PATCH
+	JsonMethod string
+	//JsonHeaders are additionnal HTTP headers sent with the json logs (e.g. an Authorization header,
+	//see SetBearerTokenFromEnv())
+	JsonHeaders http.Header
+	//SortAttrs causes the attributes (additionnal, record and context attributes) to be sorted
+	//by key (group prefix included) in text and json logs, the attributes with the same key
+	//keeping their order. The envelope fields (time, level, message...) stay first
+	SortAttrs bool
+	//AddSequence causes a SEQUENCE_KEY attribute to be added to the logs, holding a number
+	//incremented for each handled record (shared by the handler and the handlers derived from it),
+	//to order the logs with the same time. It is a root attribute rewritten by ReplaceAttr like the others
+	AddSequence bool
+	//JsonMarshal is the function marshalling the json logs (sent to JsonLogURL, written on JsonWriter
+	//or rendered with FormatJSON), e.g. a faster encoder or one with another escaping behavior.
+	//If nil, json.Marshal is used
+	JsonMarshal func(v any) ([]byte, error)
+	//OnRecord is an optional hook called synchronously after each handled record is logged
+	//(e.g. to trigger an alert on the Error logs), with the record as logged : its attributes
+	//processed by ReplaceAttr and the redaction. It is called once the CustomLogger releases the handler,
+	//so that it can log with the same logger. A panic in the hook is recovered and doesn't crash the logger
+	OnRecord func(ctx context.Context, r slog.Record)
+	//JsonClient is the *http.Client sending the json logs to JsonLogURL
+	//(e.g. with a custom transport, see WithJSONTLS()). If nil, a default http.Client is used,
+	//sharing its connections between the handlers (see DefaultJSONTransport())
+	JsonClient *http.Client
+	//ErrorKeys are the keys (case-insensitive) of the attributes whose value is rendered
+	//in the ErrorValue color of the ColorPalette in colorized text logs, as the error values.
+	//If nil, DEFAULT_ERROR_KEYS are used
+	ErrorKeys []string
+	//MaxAttrs is the maximum number of attributes of a log (0 for no limit) :
+	//the attributes beyond are dropped and a TRUNCATED_KEY attribute is added
+	MaxAttrs int
+	//MaxJsonBytes is the maximum size of a json log (0 for no limit) :
+	//the attributes of a bigger json log are dropped and a TRUNCATED_KEY field is added.
+	//If it is still too big, the json log is dropped (see HandlerStats.DroppedBySizeCount)
+	MaxJsonBytes int
+	//BannerWidth is the width of the separator lines of the FormatBanner text logs,
+	//the header line being padded so that its width doesn't depend on the level.
+	//If 0, DEFAULT_BANNER_WIDTH is used
+	BannerWidth int
+	//InternalErrorHandler is called with the internal errors of the logger (e.g. a json log
+	//which couldn't be sent to JsonLogURL), at most once per InternalErrorInterval so that
+	//an unavailable log service doesn't flood the program. If nil, the errors are printed on os.Stderr
+	InternalErrorHandler func(err error)
+	//InternalErrorInterval is the minimum duration between two reported internal errors,
+	//the errors in between being ignored. If 0, DEFAULT_INTERNAL_ERROR_INTERVAL is used
+	InternalErrorInterval time.Duration
+	//TimeLayout is the layout of the time attribute values in the text logs.
+	//If empty, time.RFC3339 is used
+	TimeLayout string
+	//JsonDurationNanos causes the duration attribute values to be represented in the json logs
+	//by their number of nanoseconds instead of their string (e.g. "1.5s")
+	JsonDurationNanos bool
+	//OTLPEndpoint is the URL of the OTLP/HTTP logs endpoint of an OpenTelemetry collector
+	//(e.g. "http://localhost:4318/v1/logs"). If not empty, the handler sends the logs to it
+	//as OTLP json log records, with the JsonHeaders, JsonGzip and JsonTimeout options of the json logs.
+	//The OTLP logs are limited as the json logs (JsonAllowKeys, JsonMaxValueLength, JsonValidate and MaxJsonBytes options)
+	OTLPEndpoint string
+	//AttrOrder is the order of the attributes in the logs : by default (AttrOrderAdditionalFirst),
+	//the additionnal attributes (With()), then the record attributes, then the context attributes.
+	//AttrOrderRecordFirst puts the record attributes (the most specific ones) first.
+	//In both orders, a record attribute overrides an additionnal attribute with the same key,
+	//and a context attribute overrides both
+	AttrOrder AttrOrder
+	//SourceFunction causes the source of the text logs (if AddSource is true) to include
+	//the function of the caller : "@pkg.Func (file.go:42)".
+	//The source of the json logs is always an object with the function, file and line fields
+	SourceFunction bool
+	//SourceHyperlink causes the source of the colorized text logs (if AddSource is true) to be an OSC 8
+	//hyperlink to the SourceURLTemplate, clickable in the terminals supporting it (the others showing the plain source)
+	SourceHyperlink bool
+	//SourceURLTemplate is the URL of the source hyperlinks (see SourceHyperlink option),
+	//where {path} and {line} are replaced by the file and line of the source, e.g. "vscode://file/{path}:{line}"
+	//(DEFAULT_SOURCE_URL_TEMPLATE by default)
+	SourceURLTemplate string
+	//NoTime causes the time of the logs to be omitted from the text and json logs
+	//(e.g. when the lines are already timestamped by systemd-journald).
+	//The time key is then not reserved anymore in the json logs : a time attribute
+	//(e.g. added by ReplaceAttr or With()) is logged with its own key
+	NoTime bool
+	//GenerateCorrelationID causes a correlation id to be logged with each log, under the CorrelationIDKey :
+	//the id of the context under this key (as a CtxKeyString or a string key) if any,
+	//else an UUID generated once for the logger, and again for each logger derived from it
+	GenerateCorrelationID bool
+	//CorrelationIDKey is the key of the correlation id. If empty, DEFAULT_CORRELATION_ID_KEY is used
+	CorrelationIDKey string
+	//CEFVendor, CEFProduct and CEFVersion are the device vendor, product and version
+	//header fields of the FormatCEF text logs. If empty, DEFAULT_CEF_VENDOR,
+	//DEFAULT_CEF_PRODUCT and DEFAULT_CEF_VERSION are used
+	CEFVendor  string
+	CEFProduct string
+	CEFVersion string
+	//SyncJson causes the json logs to be sent to JsonLogURL (and OTLPEndpoint) synchronously :
+	//Handle() waits for the delivery (at most JsonTimeout) and returns its error.
+	//Note that the logging methods of slog.Logger ignore the errors of Handle(),
+	//which are still reported to the InternalErrorHandler
+	SyncJson bool
+	//JsonAllowKeys, if not empty, is the list of the only attributes kept in the json logs
+	//sent to JsonLogURL or OTLPEndpoint and written on JsonWriter (e.g. the fields approved to leave the host),
+	//the text logs keeping all the attributes. The keys of the attributes in groups are given with
+	//their dotted group prefix (e.g. "request.method"), a group key keeping all its members.
+	//The envelope fields (time, level, message, source and component) are always kept
+	JsonAllowKeys []string
+	//AttrPrefix is the prefix of the attribute lines of the FormatBanner text logs (e.g. "  "),
+	//its leading spaces or tabs being repeated to indent the members of the groups.
+	//If empty, DEFAULT_ATTR_PREFIX is used
+	AttrPrefix string
+	//AttrSeparator is the separator between the key and the value of the attributes
+	//of the FormatBanner text logs (e.g. "="). If empty, DEFAULT_ATTR_SEPARATOR is used
+	AttrSeparator string
+	//JsonValidate is an optional validator of the json logs (e.g. a schema check in tests), called
+	//before they are marshalled. A json log it rejects is neither sent nor written : its error is
+	//returned by Handle() and reported to the InternalErrorHandler. The slices, maps and structs
+	//of the attributes are already marshalled in it, as json.RawMessage values
+	JsonValidate func(data map[string]interface{}) error
+	//Now is the clock giving the time of the records without time (e.g. records built with a zero time
+	//and passed to Handle()), to freeze the time of the logs in tests. If nil, time.Now is used
+	Now func() time.Time
+	//StaticFields are process-wide fields (e.g. the host, the pid and the version of the service,
+	//see DefaultStaticFields()) logged at the root of every log of the handler and of the handlers
+	//derived from it, before the other attributes which override them. They are resolved once,
+	//when the handler is created
+	StaticFields map[string]any
+	//ValueColorRules colorize the numeric values of the attributes above thresholds in colorized text logs
+	//(e.g. latency_ms in yellow above 500 and in red above 1000), the rule with the highest exceeded
+	//threshold of the key being applied. The error values keep the ErrorValue color
+	ValueColorRules []ValueColorRule
+	//TextMinimumLevel and JsonMinimumLevel are the minimum levels of the text logs and of the json logs
+	//(sent to JsonLogURL or OTLPEndpoint, or written on JsonWriter), e.g. Debug text logs on the console
+	//but only Warn json logs sent to the log service. If nil, the MinimumLevel is used.
+	//A record is handled if its level reaches the minimum level of one of the sinks
+	TextMinimumLevel slog.Leveler
+	JsonMinimumLevel slog.Leveler
+	//AddScope causes a SCOPE_KEY field holding the dotted group path of the logger (e.g. "a.b"
+	//after WithGroup("a").WithGroup("b")) to be added at the root of the json logs,
+	//to filter the logs of a group in the log service
+	AddScope bool
+	//AddContextDeadline causes a DEADLINE_REMAINING_KEY attribute holding the time remaining before
+	//the deadline of the context (negative once it is exceeded) to be added to the logs of the contexts
+	//with a deadline, e.g. to debug the latency of a request
+	AddContextDeadline bool
+	//JsonDryRun causes the json logs to be built and marshalled as usual, but written on the
+	//JsonDryRunWriter instead of being sent to JsonLogURL or OTLPEndpoint, e.g. to check the payloads
+	//of a new log service before going live. Each payload is written on a line, after its method and url
+	JsonDryRun bool
+	//JsonDryRunWriter is the io.Writer of the payloads of the JsonDryRun option (os.Stderr by default)
+	JsonDryRunWriter io.Writer
+	//PayloadFormat is the serialization of the logs sent to JsonLogURL : PayloadJSON (by default)
+	//or PayloadMsgpack, for a lower bandwidth. The JsonWriter still gets json logs
+	PayloadFormat PayloadFormat
+	//NestAttrsUnder is the key of an object nesting all the attributes of the json logs (e.g. "attrs"),
+	//so that they never collide with the envelope fields (time, level, msg...).
+	//If empty, the attributes are at the root of the json logs, those colliding with an envelope field
+	//being prefixed by "attr_"
+	NestAttrsUnder string
+	//AddGoroutineID causes a GOROUTINE_KEY attribute holding the id of the goroutine logging the record
+	//to be added to the logs, e.g. to correlate the logs of concurrent requests.
+	//Reading the id takes a goroutine stack trace for each log : it is meant for debugging only
+	AddGoroutineID bool
+	//MaxMessageLength is the maximum length in bytes of the messages (0 for no limit).
+	//The longer messages are truncated on a character boundary, followed by "…",
+	//and a MESSAGE_TRUNCATED_KEY attribute is added to their logs
+	MaxMessageLength int
+	//JsonMaxValueLength is the maximum length in bytes of the string attribute values of the json logs
+	//(0 for no limit), e.g. to limit the size of the payloads sent to JsonLogURL. The longer values are truncated
+	//on a character boundary, followed by "…", and a "<key>_truncated" field is added next to them.
+	//The text logs keep the full values
+	JsonMaxValueLength int
+}
+
+// AttrOrder is the order of the attributes in the logs
+type AttrOrder int
+
+const (
+	//AttrOrderAdditionalFirst renders the additionnal attributes, then the record attributes,
+	//then the context attributes (default)
+	AttrOrderAdditionalFirst AttrOrder = iota
+	//AttrOrderRecordFirst renders the record attributes, then the additionnal attributes,
+	//then the context attributes
+	AttrOrderRecordFirst
+)
+
+// jsonMethods are the HTTP methods allowing a body, usable to send the json logs
+var jsonMethods = []string{http.MethodPost, http.MethodPut, http.MethodPatch}
+
+// jsonMethod returns the JsonMethod option or its default value, or an error if it isn't allowed
+func (o *CustomHandlerOptions) jsonMethod() (string, error) {
+	if o.JsonMethod == "" {
+		return http.MethodPost, nil
+	}
+	method := strings.ToUpper(o.JsonMethod)
+	if !slices.Contains(jsonMethods, method) {
+		return "", fmt.Errorf("invalid JsonMethod %q : must be one of %s", o.JsonMethod, strings.Join(jsonMethods, ", "))
+	}
+	return method, nil
+}
+
+// SetBearerTokenFromEnv() reads a token from the envVar environment variable
+// and sets it as a bearer token in the Authorization header of the JsonHeaders,
+// so that the secret is not hardcoded. An error is returned if the variable is empty
+func (o *CustomHandlerOptions) SetBearerTokenFromEnv(envVar string) error {
+	token := os.Getenv(envVar)
+	if token == "" {
+		return fmt.Errorf("environment variable %s is empty", envVar)
+	}
+	if o.JsonHeaders == nil {
+		o.JsonHeaders = http.Header{}
+	}
+	o.JsonHeaders.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// JsonFieldNames defines the keys of the envelope fields of the json logs.
+// An empty key uses the default one
+type JsonFieldNames struct {
+	//Time is the key of the time of the log ("time" by default)
+	Time string
+	//Level is the key of the level of the log ("level" by default)
+	Level string
+	//Message is the key of the message of the log ("msg" by default)
+	Message string
+	//Source is the key of the source of the log, if AddSource option is true ("source" by default)
+	Source string
+}
+
+// withDefaults() returns the field names, with the default names for the empty ones
+func (n JsonFieldNames) withDefaults() JsonFieldNames {
+	if n.Time == "" {
+		n.Time = "time"
+	}
+	if n.Level == "" {
+		n.Level = "level"
+	}
+	if n.Message == "" {
+		n.Message = "msg"
+	}
+	if n.Source == "" {
+		n.Source = "source"
+	}
+	return n
+}
+
+// DEFAULT_JSON_TIMEOUT is the default duration of the wait for the sending of a json log
+const DEFAULT_JSON_TIMEOUT = time.Second
+
+// jsonTimeout returns the JsonTimeout option or its default value
+func (o *CustomHandlerOptions) jsonTimeout() time.Duration {
+	if o.JsonTimeout <= 0 {
+		return DEFAULT_JSON_TIMEOUT
+	}
+	return o.JsonTimeout
+}
+
+// validateURL(name, rawURL) checks that the url option is empty or an http(s) url with a host
+func validateURL(name, rawURL string) error {
+	if rawURL == "" {
+		return nil
+	}
+	if u, err := url.Parse(rawURL); err != nil {
+		return fmt.Errorf("invalid %s : %w", name, err)
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid %s %q : scheme must be http or https", name, rawURL)
+	} else if u.Host == "" {
+		return fmt.Errorf("invalid %s %q : missing host", name, rawURL)
+	}
+	return nil
+}
+
+// Validate() checks that the options are consistent :
+// the JsonLogURL and OTLPEndpoint must be absolute http or https URLs, the JsonTimeout must not be negative
+// and the colors of the ColorPalette must be ANSI escape sequences
+func (o *CustomHandlerOptions) Validate() error {
+	errs := []error{}
+
+	if err := validateURL("JsonLogURL", o.JsonLogURL); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := validateURL("OTLPEndpoint", o.OTLPEndpoint); err != nil {
+		errs = append(errs, err)
+	}
+
+	if _, err := o.jsonMethod(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if o.JsonTimeout < 0 {
+		errs = append(errs, fmt.Errorf("invalid JsonTimeout %s : must not be negative", o.JsonTimeout))
+	}
+
+	if o.PayloadFormat != PayloadJSON && o.PayloadFormat != PayloadMsgpack {
+		errs = append(errs, fmt.Errorf("invalid PayloadFormat %d : must be PayloadJSON or PayloadMsgpack", o.PayloadFormat))
+	}
+
+	if _, err := compilePatterns(o.SuppressPatterns); err != nil {
+		errs = append(errs, fmt.Errorf("invalid SuppressPatterns : %w", err))
+	}
+
+	if _, err := compilePatterns(o.ForcePatterns); err != nil {
+		errs = append(errs, fmt.Errorf("invalid ForcePatterns : %w", err))
+	}
+
+	if strings.ContainsAny(o.AttrPrefix, "\r\n") {
+		errs = append(errs, fmt.Errorf("invalid AttrPrefix %q : must not contain line breaks", o.AttrPrefix))
+	}
+
+	if strings.ContainsAny(o.AttrSeparator, "\r\n") {
+		errs = append(errs, fmt.Errorf("invalid AttrSeparator %q : must not contain line breaks", o.AttrSeparator))
+	}
+
+	if err := o.ColorPalette.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("invalid ColorPalette : %w", err))
+	}
+
+	for _, rule := range o.ValueColorRules {
+		if !ansiSequence.MatchString(rule.Color) {
+			errs = append(errs, fmt.Errorf("invalid ValueColorRules : color %q of %s is not an ANSI escape sequence", rule.Color, rule.Key))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// CtxExtractor is a function returning attributes to log from a context
+type CtxExtractor func(ctx context.Context) []slog.Attr
+
+// STACKTRACE_KEY is the key of the stack trace attribute (see StacktraceLevel option)
+const STACKTRACE_KEY = "stacktrace"
+
+// COMPONENT_KEY is the key of the component field of the json logs (see Named())
+const COMPONENT_KEY = "component"
+
+// DEADLINE_REMAINING_KEY is the key of the attribute of the time remaining before the deadline
+// of the context (see AddContextDeadline option)
+const DEADLINE_REMAINING_KEY = "deadline_remaining"
+
+// MESSAGE_TRUNCATED_KEY is the key of the attribute added to the logs whose message is truncated
+// (see MaxMessageLength option)
+const MESSAGE_TRUNCATED_KEY = "msg_truncated"
+
+// GOROUTINE_KEY is the key of the goroutine id attribute (see AddGoroutineID option)
+const GOROUTINE_KEY = "goroutine"
+
+// NO_SOURCE_KEY is the key of the sentinel attribute suppressing the source of a single log (see NoSource())
+const NO_SOURCE_KEY = "_nosource"
+
+// NoSource() returns the sentinel attribute suppressing the computation of the source of the log
+// it is passed to, even if the AddSource option is true, e.g. for the hot debug logs.
+// It is stripped from the log :
+//
+//	logger.Debug("frame received", "size", n, customsloglogger.NoSource())
+func NoSource() slog.Attr {
+	return slog.Bool(NO_SOURCE_KEY, true)
+}
+
+// SCOPE_KEY is the key of the scope field of the json logs (see AddScope option)
+const SCOPE_KEY = "scope"
+
+// ERROR_KEY is the key of the error attribute added by WithError()
+const ERROR_KEY = "error"
+
+// TRUNCATED_KEY is the key of the attribute added to the logs whose attributes are truncated
+// (see MaxAttrs and MaxJsonBytes options)
+const TRUNCATED_KEY = "truncated"
+
+// DEFAULT_ERROR_KEYS are the default keys of the attributes colorized as errors (see ErrorKeys option)
+var DEFAULT_ERROR_KEYS = []string{"err", ERROR_KEY}
+
+// SEQUENCE_KEY is the key of the sequence number attribute added with the AddSequence option
+const SEQUENCE_KEY = "seq"
+
+// DEFAULT_INTERNAL_ERROR_INTERVAL is the default minimum duration between two reported internal errors
+const DEFAULT_INTERNAL_ERROR_INTERVAL = 5 * time.Second
+
+// jsonDryRunWriter() returns the JsonDryRunWriter option or os.Stderr if it isn't defined
+func (o *CustomHandlerOptions) jsonDryRunWriter() io.Writer {
+	if o.JsonDryRunWriter == nil {
+		return os.Stderr
+	}
+	return o.JsonDryRunWriter
+}
+
+// DEFAULT_SOURCE_URL_TEMPLATE is the default URL of the source hyperlinks (see SourceHyperlink option)
+const DEFAULT_SOURCE_URL_TEMPLATE = "file://{path}"
+
+// sourceURLTemplate() returns the SourceURLTemplate option or its default value
+func (o *CustomHandlerOptions) sourceURLTemplate() string {
+	if o.SourceURLTemplate == "" {
+		return DEFAULT_SOURCE_URL_TEMPLATE
+	}
+	return o.SourceURLTemplate
+}
+
+// timeLayout() returns the TimeLayout option or its default value
+func (o *CustomHandlerOptions) timeLayout() string {
+	if o.TimeLayout == "" {
+		return time.RFC3339
+	}
+	return o.TimeLayout
+}
+
+// DEFAULT_BANNER_WIDTH is the default width of the separator lines of the banner text logs
+const DEFAULT_BANNER_WIDTH = 36
+
+// bannerWidth() returns the BannerWidth option or its default value
+func (o *CustomHandlerOptions) bannerWidth() int {
+	if o.BannerWidth <= 0 {
+		return DEFAULT_BANNER_WIDTH
+	}
+	return o.BannerWidth
+}
+
+// Here are the default AttrPrefix and AttrSeparator of the banner text logs : "\t- key : value"
+const (
+	DEFAULT_ATTR_PREFIX    = "\t- "
+	DEFAULT_ATTR_SEPARATOR = " : "
+)
+
+// attrPrefix() returns the AttrPrefix option or its default value
+func (o *CustomHandlerOptions) attrPrefix() string {
+	if o.AttrPrefix == "" {
+		return DEFAULT_ATTR_PREFIX
+	}
+	return o.AttrPrefix
+}
+
+// attrSeparator() returns the AttrSeparator option or its default value
+func (o *CustomHandlerOptions) attrSeparator() string {
+	if o.AttrSeparator == "" {
+		return DEFAULT_ATTR_SEPARATOR
+	}
+	return o.AttrSeparator
+}
+
+// staticAttrs() returns the attributes of the StaticFields option sorted by key, with their values resolved
+func (o *CustomHandlerOptions) staticAttrs() []slog.Attr {
+	if len(o.StaticFields) == 0 {
+		return nil
+	}
+	attrs := make([]slog.Attr, 0, len(o.StaticFields))
+	for key, value := range o.StaticFields {
+		attrs = append(attrs, slog.Attr{Key: key, Value: slog.AnyValue(value).Resolve()})
+	}
+	slices.SortFunc(attrs, func(a, b slog.Attr) int { return strings.Compare(a.Key, b.Key) })
+	return attrs
+}
+
+// DefaultStaticFields(version) returns the usual StaticFields of a service :
+// its "host" name, its "pid" and its "service_version"
+func DefaultStaticFields(version string) map[string]any {
+	hostname, _ := os.Hostname()
+	return map[string]any{
+		"host":            hostname,
+		"pid":             os.Getpid(),
+		"service_version": version,
+	}
+}
+
+// textLevel() returns the level of the TextMinimumLevel option, or the MinimumLevel if it isn't defined
+func (o *CustomHandlerOptions) textLevel() slog.Level {
+	if o.TextMinimumLevel == nil {
+		return o.MinimumLevel
+	}
+	return o.TextMinimumLevel.Level()
+}
+
+// jsonLevel() returns the level of the JsonMinimumLevel option, or the MinimumLevel if it isn't defined
+func (o *CustomHandlerOptions) jsonLevel() slog.Level {
+	if o.JsonMinimumLevel == nil {
+		return o.MinimumLevel
+	}
+	return o.JsonMinimumLevel.Level()
+}
+
+// minimumLevel() returns the lowest minimum level of the sinks
+func (o *CustomHandlerOptions) minimumLevel() slog.Level {
+	return min(o.textLevel(), o.jsonLevel())
+}
+
+// now() returns the current time of the Now option, or of time.Now if it isn't defined
+func (o *CustomHandlerOptions) now() time.Time {
+	if o.Now == nil {
+		return time.Now()
+	}
+	return o.Now()
+}
+
+// jsonMarshal() returns the JsonMarshal option or json.Marshal if it isn't defined
+func (o *CustomHandlerOptions) jsonMarshal() func(v any) ([]byte, error) {
+	if o.JsonMarshal == nil {
+		return json.Marshal
+	}
+	return o.JsonMarshal
+}
+
+// DEFAULT_JSON_GZIP_MIN_SIZE is the default minimum size of a json log to be compressed
+const DEFAULT_JSON_GZIP_MIN_SIZE = 1024
+
+// jsonGzipMinSize returns the JsonGzipMinSize option or its default value
+func (o *CustomHandlerOptions) jsonGzipMinSize() int {
+	if o.JsonGzipMinSize == 0 {
+		return DEFAULT_JSON_GZIP_MIN_SIZE
+	}
+	return o.JsonGzipMinSize
 }
 
 // CustomHandler is the custom slog handler, implementing the slog.Handler interface
@@ -73,6 +711,8 @@ type CustomHandler struct {
 	TextWriter io.Writer
 	//GroupName is an optional string the differents attributes will be grouped in
 	//for text logging or for JSON logs sent to third party server.
+	//Nested groups are joined with a dot (e.g. "request.headers"), a GroupName passed
+	//when creating a CustomHandler being a single group even if it contains dots
 	//GroupName can be passed when creating new CustomHandler but a better approach
 	//is to generate a new CustomHandler from another one, using the WithGroup() method of the CustomLogger
 	//to pass group name
@@ -93,6 +733,17 @@ type CustomHandler struct {
 	//is to generate a new CustomHandler from another one, using the WithCtxAttrsKeys of the CustomLogger
 	//CtxAttrsKeys
 	CtxAttrsKeys []CtxKeyString
+	//Component is an optional name of the subsystem logging (e.g. "db", "http.router")
+	//logged as a dedicated COMPONENT_KEY field.
+	//Component can be passed when creating new CustomHandler but a better approach
+	//is to generate a new CustomHandler from another one, using the Named() method of the CustomLogger
+	Component string
+	//groupPath is the group path of the handler, GroupName being its dotted representation
+	//(a group name may contain a dot)
+	groupPath []string
+	//parentAttrs are the additionnal attributes added before the last WithGroup() calls,
+	//which belong to the parent groups and not to GroupName
+	parentAttrs []groupedAttrs
 	//Options are the *CustomHandlerOptions
 	Options *CustomHandlerOptions
 	//logText defines if the handler log in writer
@@ -101,6 +752,79 @@ type CustomHandler struct {
 	logJson bool
 	//add Mutex to concurrent safety while modifying logText or logJson
 	*sync.Mutex
+	//deferHooks is true while a CustomLogger locks the handler, the OnRecord hooks being deferred
+	//in pendingHooks until it is unlocked (see lockSinks() and unlockSinks())
+	deferHooks   bool
+	pendingHooks []func()
+	//shared is the state shared by the handler and all the handlers derived from it
+	shared *handlerShared
+	//sampler drops the records exceeding the sampling options (nil if sampling is disabled)
+	sampler *sampler
+	//filter filters the records on their message (nil if no pattern is defined)
+	filter *messageFilter
+	//memory captures the records of a MemoryHandler (nil for the other handlers)
+	memory *recordStore
+	//generatedID is the correlation id generated for the handler (see GenerateCorrelationID option)
+	generatedID string
+	//staticAttrs are the attributes of the StaticFields option, resolved when the handler is created
+	staticAttrs []slog.Attr
+	//ctxAttrs are the context values logged as renamed attributes (see WithCtxAttr())
+	ctxAttrs []ctxAttr
+}
+
+// ctxAttr is a context key whose value is logged as the attribute named name
+type ctxAttr struct {
+	key  any
+	name string
+}
+
+// handlerShared is the state shared by a handler and all the handlers derived from it
+type handlerShared struct {
+	//writeMu synchronizes the writes of the text logs,
+	//so that logs written on the same writer are not interleaved
+	writeMu sync.Mutex
+	//deliveries tracks the json logs being sent to JsonLogURL.
+	//closeMu guards closed, set by Close() : no delivery is started once it waits for them
+	deliveries sync.WaitGroup
+	closeMu    sync.RWMutex
+	closed     bool
+	//counters of the handler statistics (see HandlerStats)
+	delivered      atomic.Uint64
+	failed         atomic.Uint64
+	droppedByLevel atomic.Uint64
+	timedOut       atomic.Uint64
+	droppedBySize  atomic.Uint64
+	//lastError is the time (in unix nanoseconds) of the last reported internal error
+	lastError atomic.Int64
+	//seq is the last sequence number of the records (see AddSequence option)
+	seq atomic.Uint64
+}
+
+// HandlerStats is a snapshot of the statistics of a handler and of the handlers derived from it
+type HandlerStats struct {
+	//DeliveredCount is the number of json logs successfully sent to JsonLogURL
+	DeliveredCount uint64
+	//FailedCount is the number of json logs which couldn't be sent to JsonLogURL
+	//(request error or non 2xx response status)
+	FailedCount uint64
+	//DroppedByLevelCount is the number of records dropped because of their level
+	DroppedByLevelCount uint64
+	//TimedOutCount is the number of json logs not sent after the JsonTimeout
+	//(the sending continues in background and is then counted as delivered or failed)
+	TimedOutCount uint64
+	//DroppedBySizeCount is the number of json logs dropped because they exceed the MaxJsonBytes option
+	DroppedBySizeCount uint64
+}
+
+// Stats() returns a snapshot of the statistics of the handler
+func (m *CustomHandler) Stats() HandlerStats {
+	return HandlerStats{
+		DeliveredCount:      m.shared.delivered.Load(),
+		FailedCount:         m.shared.failed.Load(),
+		DroppedByLevelCount: m.shared.droppedByLevel.Load(),
+		TimedOutCount:       m.shared.timedOut.Load(),
+		DroppedBySizeCount:  m.shared.droppedBySize.Load(),
+	}
 }
 
 // Clone "clones" a CustomHandler
@@ -110,24 +834,186 @@ func (c *CustomHandler) Clone() *CustomHandler {
 		logJson:          true,
 		TextWriter:       c.TextWriter,
 		GroupName:        c.GroupName,
+		groupPath:        c.groupPath,
+		Component:        c.Component,
 		Mutex:            &sync.Mutex{},
+		shared:           c.shared,
+		sampler:          c.sampler,
+		filter:           c.filter,
+		memory:           c.memory,
+		generatedID:      c.Options.generatedID(),
+		staticAttrs:      c.staticAttrs,
+		ctxAttrs:         slices.Clone(c.ctxAttrs),
 		CtxAttrsKeys:     slices.Clone(c.CtxAttrsKeys),
 		AdditionnalAttrs: slices.Clone(c.AdditionnalAttrs),
-		Options: &CustomHandlerOptions{
-			AddSource:    c.Options.AddSource,
-			ColorizeLogs: c.Options.ColorizeLogs,
-			JsonLogURL:   c.Options.JsonLogURL,
-			MinimumLevel: c.Options.MinimumLevel,
-		},
+		parentAttrs:      slices.Clone(c.parentAttrs),
+		Options:          c.Options.clone(),
+	}
+}
+
+// clone returns a copy of the options
+func (o *CustomHandlerOptions) clone() *CustomHandlerOptions {
+	options := *o
+	options.RedactKeys = slices.Clone(o.RedactKeys)
+	options.LevelWriters = maps.Clone(o.LevelWriters)
+	options.SamplerLevels = maps.Clone(o.SamplerLevels)
+	options.CtxExtractors = slices.Clone(o.CtxExtractors)
+	options.SuppressPatterns = slices.Clone(o.SuppressPatterns)
+	options.ForcePatterns = slices.Clone(o.ForcePatterns)
+	options.ErrorKeys = slices.Clone(o.ErrorKeys)
+	options.JsonHeaders = o.JsonHeaders.Clone()
+	options.JsonAllowKeys = slices.Clone(o.JsonAllowKeys)
+	options.StaticFields = maps.Clone(o.StaticFields)
+	options.ValueColorRules = slices.Clone(o.ValueColorRules)
+	return &options
+}
+
+// groupedAttrs are attributes belonging to a group path
+type groupedAttrs struct {
+	groups []string
+	attrs  []slog.Attr
+}
+
+// handledAttr is an attribute to log, with the group path it belongs to
+// and the corresponding dotted prefix for text logs
+type handledAttr struct {
+	groups []string
+	prefix string
+	slog.Attr
+	//record is true for the attributes of the record (see AttrOrder option)
+	record bool
+}
+
+// appendAttr(attrs, groups, prefix, a) appends the attribute to the attributes to log.
+// Like slog does, the members of a group without key are inlined and the empty groups are ignored
+func appendAttr(attrs []handledAttr, groups []string, prefix string, a slog.Attr) []handledAttr {
+	if a.Value.Kind() == slog.KindGroup {
+		members := a.Value.Group()
+		if len(members) == 0 {
+			return attrs
+		}
+		if a.Key == "" {
+			for _, member := range members {
+				attrs = appendAttr(attrs, groups, prefix, member)
+			}
+			return attrs
+		}
+	}
+	return append(attrs, handledAttr{groups: groups, prefix: prefix, Attr: a})
+}
+
+// dedupeAttrs(attrs) removes the attributes whose key is used again later in the same group,
+// so that the last value of a key wins (e.g. a record attribute overriding an additionnal attribute)
+func dedupeAttrs(attrs []handledAttr) []handledAttr {
+	deduped := attrs[:0]
+	for i, a := range attrs {
+		overridden := false
+		for _, next := range attrs[i+1:] {
+			if next.Key == a.Key && next.prefix == a.prefix {
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			deduped = append(deduped, a)
+		}
 	}
+	return deduped
+}
+
+// groups() returns the group path of the handler (see WithGroup()), or the single GroupName group
+// if the GroupName was passed when creating the CustomHandler. It must not be modified
+func (m *CustomHandler) groups() []string {
+	if m.groupPath != nil {
+		return m.groupPath
+	}
+	if m.GroupName != "" {
+		return []string{m.GroupName}
+	}
+	return []string{}
+}
+
+// groupPrefix(groups) returns the prefix of the keys of the attributes
+// belonging to the group path in text logs
+func groupPrefix(groups []string) string {
+	if len(groups) == 0 {
+		return ""
+	}
+	return strings.Join(groups, ".") + "."
+}
+
+// isRedacted(groups, key) checks if the key (or the key prefixed by its dotted group path)
+// is one of the RedactKeys option
+func (m *CustomHandler) isRedacted(groups []string, key string) bool {
+	qualifiedKey := strings.Join(append(slices.Clip(groups), key), ".")
+	for _, redactKey := range m.Options.RedactKeys {
+		if strings.EqualFold(redactKey, key) || strings.EqualFold(redactKey, qualifiedKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// replaceAttr(groups, a) returns the attribute as it must be logged :
+// its value is resolved (see slog.LogValuer), the ReplaceAttr option is applied on it, then its value is replaced by REDACTED_VALUE
+// if its key is one of the RedactKeys option.
+// Grouped attributes (slog.Group) are handled recursively.
+// A zero slog.Attr is returned if the attribute must be dropped
+func (m *CustomHandler) replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+
+	if a.Value.Kind() != slog.KindGroup && m.Options.ReplaceAttr != nil {
+		a = m.Options.ReplaceAttr(slices.Clip(groups), a)
+		if a.Equal(slog.Attr{}) {
+			return a
+		}
+	}
+
+	if m.isRedacted(groups, a.Key) {
+		return slog.String(a.Key, REDACTED_VALUE)
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		groupAttrs := a.Value.Group()
+		replaced := make([]slog.Attr, 0, len(groupAttrs))
+		for _, attr := range groupAttrs {
+			if attr = m.replaceAttr(append(slices.Clip(groups), a.Key), attr); !attr.Equal(slog.Attr{}) {
+				replaced = append(replaced, attr)
+			}
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(replaced...)}
+	}
+
+	return a
 }
 
 // Enabled : interface Handler method
 // If true is returned, the Record will be handled.
 // True is returned when the level of the Record is at least
-// the minimum level defined in CustomHandlerOption
+// the minimum level defined in CustomHandlerOption,
+// or for every level if ForcePatterns are defined (the message being checked in Handle())
 func (m *CustomHandler) Enabled(ctx context.Context, level slog.Level) bool {
-	return level >= m.Options.MinimumLevel.Level()
+	if m.enabled(level) {
+		return true
+	}
+	m.shared.droppedByLevel.Add(1)
+	return false
+}
+
+// enabled(level) returns true if the records of the level may be handled,
+// without counting the dropped records
+func (m *CustomHandler) enabled(level slog.Level) bool {
+	return level >= m.Options.minimumLevel() || m.filter.forcing()
+}
+
+// Enabled() returns true if the logger handles the records of the level,
+// e.g. to skip building an expensive debug message.
+// Unlike the records dropped while logging, a check doesn't count in the Stats()
+func (c *CustomLogger) Enabled(ctx context.Context, level slog.Level) bool {
+	if h := c.Handler(); h != nil {
+		return h.enabled(level)
+	}
+	return c.Logger.Enabled(ctx, level)
 }
 
 func (l *CustomLogger) With(args ...any) *CustomLogger {
@@ -137,18 +1023,48 @@ func (l *CustomLogger) With(args ...any) *CustomLogger {
 			attrs = append(attrs, slog.Attr{Key: fmt.Sprintf("%s", args[i]), Value: slog.AnyValue(args[i+1])})
 		}
 	}
-	return &CustomLogger{slog.New(l.Handler().WithAttrs(attrs))}
+	return &CustomLogger{slog.New(l.Logger.Handler().WithAttrs(attrs))}
+}
+
+// WithError() returns a new *CustomLogger based on the first one, with the error
+// as an ERROR_KEY additionnal attribute (rendered with its wrapped errors chain).
+// If err is nil, the logger itself is returned
+func (l *CustomLogger) WithError(err error) *CustomLogger {
+	if err == nil {
+		return l
+	}
+	return &CustomLogger{slog.New(l.Logger.Handler().WithAttrs([]slog.Attr{slog.Any(ERROR_KEY, err)}))}
+}
+
+// WrapError(err, msg, args...) logs the message at Error level with the attributes and the error
+// as an ERROR_KEY attribute, then returns the error wrapped with the message ("msg: err"), to log
+// and return an error in one line. If err is nil, nothing is logged and nil is returned :
+//
+//	return logger.WrapError(err, "unable to load config", "path", path)
+func (l *CustomLogger) WrapError(err error, msg string, args ...any) error {
+	if err == nil {
+		return nil
+	}
+	l.log(context.TODO(), slog.LevelError, msg, true, true, append(args, slog.Any(ERROR_KEY, err))...)
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// WithGroupAttrs() returns a new *CustomLogger based on the first one, with the attributes
+// (key-value pairs or slog.Attr, as for With()) as additionnal attributes grouped under the group name.
+// Unlike WithGroup(), the attributes added afterwards (and the record attributes) are not grouped
+func (l *CustomLogger) WithGroupAttrs(group string, args ...any) *CustomLogger {
+	return &CustomLogger{slog.New(l.Logger.Handler().WithAttrs([]slog.Attr{slog.Group(group, args...)}))}
 }
 
 func (l *CustomLogger) WithGroup(name string) *CustomLogger {
-	return &CustomLogger{slog.New(l.Handler().WithGroup(name))}
+	return &CustomLogger{slog.New(l.Logger.Handler().WithGroup(name))}
 
 }
 
 // WithAttrs : interface Handler method.
 // This method is called when the With(attrs []slog.Attr) is called on an initial logger.
 // It returns a new CustomHandler, based on the initial one
-// (i.e. with the same TextWriter and same Options and same GroupName)
+// (i.e. with the same TextWriter and same Options and same GroupName and same attributes)
 // but with AdditionnalAttrs that will be logged with each Record attributes
 func (m *CustomHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	handler := m.Clone()
@@ -159,64 +1075,665 @@ func (m *CustomHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 // WithGroup : interface Handler method.
 // This method is called when the WithGroup(group string) is called on an initial logger.
 // It returns a new CustomHandler, based on the initial one
-// (i.e. with the same TextWriter and same Options and same AdditionnalAttrs)
-// but with a group name that will group every Record attributes
+// (i.e. with the same TextWriter and same Options and same attributes)
+// but with a group name, nested in the potential current one, that will group
+// every Record attributes and every attributes added afterwards.
+// The attributes added before stay in their own group
 func (m *CustomHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return m
+	}
 	handler := m.Clone()
-	handler.GroupName = name
+	if len(handler.AdditionnalAttrs) != 0 {
+		handler.parentAttrs = append(handler.parentAttrs, groupedAttrs{groups: m.groups(), attrs: handler.AdditionnalAttrs})
+		handler.AdditionnalAttrs = make([]slog.Attr, 0)
+	}
+	handler.groupPath = append(slices.Clone(m.groups()), name)
+	handler.GroupName = strings.Join(handler.groupPath, ".")
 	return handler
 }
 
-// Handle : interface Handler method.
-// This method is called when the slog.Record level is at least the minimum level
-// defined in the CustomHandlerOptions.
-// It will :
-// - concat all slog.Record attributes with potential AdditionnalAttrs
-// - group all theses attributes in a GroupName if defined
-// - get the source code line if AddSource option is true
-// - colorize all of this if ColorizeLog option is true
-// - print all the result on the TextWriter if TextLog option is true
-// - send all of this in json format to JsonLogUrl if this option is defined
-// The sending to JsonLogUrl server will be "timed out" after 1 second
-func (m *CustomHandler) Handle(ctx context.Context, r slog.Record) error {
-	//defines color / log level
-	color := COLOR_WHITE
-
-	switch r.Level {
-	case slog.LevelDebug:
-		color = COLOR_DARKGRAY
-	case slog.LevelInfo:
-		color = COLOR_BLUE
-	case slog.LevelWarn:
-		color = COLOR_YELLOW
-	case slog.LevelError:
-		color = COLOR_RED
-	}
-
-	//init potentiel groupName prefixe
-	groupPrefix := ""
-	if m.GroupName != "" {
-		groupPrefix = fmt.Sprintf("%s.", m.GroupName)
+// Handle : interface Handler method.
+// This method is called when the slog.Record level is at least the minimum level
+// defined in the CustomHandlerOptions.
+// It will :
+// - concat all slog.Record attributes with potential AdditionnalAttrs
+// - group all theses attributes in a GroupName if defined
+// - get the source code line if AddSource option is true
+// - colorize all of this if ColorizeLog option is true
+// - print all the result on the TextWriter if TextLog option is true
+// - send all of this in json format to JsonLogUrl if this option is defined
+// - write all of this in json format on JsonWriter if this option is defined
+// The sending to JsonLogUrl server will be "timed out" after the JsonTimeout option (1 second by default),
+// and canceled with the context with the SyncJson option : if the context is already done, nothing is sent.
+// A nil context is handled as context.Background().
+// The errors of the TextWriter and JsonWriter are returned (and reported to the InternalErrorHandler),
+// the other sinks being still written
+func (m *CustomHandler) Handle(ctx context.Context, r slog.Record) (err error) {
+	//tolerating a nil context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	//a canceled context means the caller (e.g. an aborted request) doesn't wait
+	//for the log anymore : the json log won't be sent
+	canceled := ctx.Err() != nil
+
+	//dropping the record if its message is suppressed,
+	//or if its level is under the level of both sinks and its message isn't forced
+	if m.filter.suppressed(r.Message) {
+		return nil
+	}
+	forced := m.filter.forced(r.Message)
+	if r.Level < m.Options.minimumLevel() && !forced {
+		m.shared.droppedByLevel.Add(1)
+		return nil
+	}
+	//a MemoryHandler only captures its records, without rendering them in text
+	logText := m.logText && m.memory == nil && (forced || r.Level >= m.Options.textLevel())
+	logJson := m.logJson && (forced || r.Level >= m.Options.jsonLevel())
+
+	//dropping the record if it exceeds the sampling
+	if m.sampler != nil && !m.sampler.sample(r.Level, r.Message, m.Options.now()) {
+		return nil
+	}
+
+	//nothing is prepared if the record is neither logged in text nor in json, nor captured or hooked
+	sendJson := m.Options.JsonLogURL != "" && !canceled
+	writeJson := logJson && (sendJson || m.Options.JsonWriter != nil)
+	sendOtlp := logJson && m.Options.OTLPEndpoint != "" && !canceled
+	if !logText && !writeJson && !sendOtlp && m.memory == nil && m.Options.OnRecord == nil {
+		return nil
+	}
+
+	hr := m.prepare(ctx, r)
+
+	//calling the OnRecord hook with the prepared record once the record is logged
+	if m.Options.OnRecord != nil {
+		defer m.onRecord(ctx, hr.record())
+	}
+
+	//capturing the record for a MemoryHandler
+	if m.memory != nil {
+		m.memory.add(hr, m.Component)
+	}
+
+	//the errors of the writers (e.g. a full disk, a closed pipe) are reported and returned,
+	//without preventing the other sinks
+	var writeErr error
+	defer func() {
+		if writeErr != nil {
+			err = errors.Join(writeErr, err)
+		}
+	}()
+
+	//final display if logText is true, before the json log so that a json failure never suppresses it
+	if logText {
+		buf := newBuffer()
+		defer freeBuffer(buf)
+		if err := m.writeText(buf, hr); err != nil {
+			return err
+		}
+		var err error
+		m.shared.writeMu.Lock()
+		if w, ok := m.textWriter(hr.Level).(levelWriter); ok {
+			_, err = w.WriteLevel(hr.Level, buf.Bytes())
+		} else {
+			_, err = m.textWriter(hr.Level).Write(buf.Bytes())
+		}
+		m.shared.writeMu.Unlock()
+		if err != nil {
+			writeErr = fmt.Errorf("unable to write text log : %w", err)
+			m.reportError(writeErr)
+		}
+	}
+
+	//sending to log microservice and writing on JsonWriter if options enable it,
+	//a json failure not preventing the OpenTelemetry export
+	var sinkErr error
+	if writeJson {
+		sinkErr = m.handleJson(ctx, hr, sendJson)
+	}
+
+	//sending to the OpenTelemetry collector if the OTLPEndpoint option is defined
+	if sendOtlp {
+		sinkErr = errors.Join(sinkErr, m.handleOtlp(ctx, hr))
+	}
+
+	return sinkErr
+}
+
+// handleJson(ctx, hr, sendJson) writes the json log of the record on the JsonWriter,
+// and sends it (or its MessagePack log) to JsonLogURL if sendJson is true
+func (m *CustomHandler) handleJson(ctx context.Context, hr *handledRecord, sendJson bool) (err error) {
+	//the errors of the JsonWriter are reported and returned, without preventing the sending
+	var writeErr error
+	defer func() {
+		if writeErr != nil {
+			err = errors.Join(writeErr, err)
+		}
+	}()
+
+	//the json log isn't marshalled if it is only sent as MessagePack
+	msgpack := sendJson && m.Options.PayloadFormat == PayloadMsgpack
+	var jsonByte []byte
+	if m.Options.JsonWriter != nil || !msgpack {
+		var err error
+		if jsonByte, err = m.jsonLog(hr); err != nil {
+			if sendJson {
+				m.shared.failed.Add(1)
+			}
+			return fmt.Errorf("unable to parse json request : %w", err)
+		}
+		if jsonByte == nil {
+			m.shared.droppedBySize.Add(1)
+			return nil
+		}
+	}
+
+	if m.Options.JsonWriter != nil {
+		m.shared.writeMu.Lock()
+		_, err := m.Options.JsonWriter.Write(append(jsonByte, '\n'))
+		m.shared.writeMu.Unlock()
+		if err != nil {
+			writeErr = fmt.Errorf("unable to write json log : %w", err)
+			m.reportError(writeErr)
+		}
+	}
+
+	if !sendJson {
+		return nil
+	}
+	method, err := m.Options.jsonMethod()
+	if err != nil {
+		m.shared.failed.Add(1)
+		return err
+	}
+	payload, contentType := jsonByte, "application/json"
+	if msgpack {
+		if payload, err = m.msgpackLog(hr); err != nil {
+			m.shared.failed.Add(1)
+			return fmt.Errorf("unable to encode msgpack request : %w", err)
+		}
+		if payload == nil {
+			m.shared.droppedBySize.Add(1)
+			return nil
+		}
+		contentType = MSGPACK_CONTENT_TYPE
+	}
+	return m.sendJson(ctx, method, m.Options.JsonLogURL, contentType, payload)
+}
+
+// handleOtlp(ctx, hr) sends the OTLP log of the record to the OTLPEndpoint
+func (m *CustomHandler) handleOtlp(ctx context.Context, hr *handledRecord) error {
+	otlpByte, err := m.otlpLog(hr)
+	if err != nil {
+		m.shared.failed.Add(1)
+		return err
+	}
+	if otlpByte == nil {
+		m.shared.droppedBySize.Add(1)
+		return nil
+	}
+	return m.sendJson(ctx, http.MethodPost, m.Options.OTLPEndpoint, "application/json", otlpByte)
+}
+
+// jsonLog(hr) returns the marshalled json log of the record, checked by the JsonValidate option.
+// If it can't be marshalled (e.g. with an attribute rejected by the JsonMarshal option), the error is reported
+// and the minimal json log of the record (its time, level and message) is returned instead.
+// If it exceeds the MaxJsonBytes option, the attributes are dropped and a TRUNCATED_KEY field is added,
+// and nil is returned if the json log is still too big
+func (m *CustomHandler) jsonLog(hr *handledRecord) ([]byte, error) {
+	return m.payloadLog(hr, m.jsonValue, m.Options.jsonMarshal())
+}
+
+// payloadLog(hr, value, marshal) returns the log of the record marshalled by marshal,
+// its attribute values being represented by value (see jsonLog())
+func (m *CustomHandler) payloadLog(hr *handledRecord, value func(slog.Value) interface{}, marshal func(any) ([]byte, error)) ([]byte, error) {
+	hr = m.truncatedRecord(m.allowedRecord(hr))
+	names := m.Options.JsonFieldNames.withDefaults()
+	data := m.payloadData(hr, value, names)
+	if err := m.validateJson(data); err != nil {
+		return nil, err
+	}
+	jsonByte, err := marshal(data)
+	if err != nil {
+		//the record isn't dropped if its attributes can't be marshalled : its minimal json log is sent
+		m.reportError(fmt.Errorf("unable to marshal json log, sending its time, level and message only : %w", err))
+		jsonByte, err = marshal(m.envelopeData(hr, 3, names))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m.sizeLimited(hr, jsonByte, func(hr *handledRecord) ([]byte, error) {
+		return marshal(m.payloadData(hr, value, names))
+	})
+}
+
+// otlpLog(hr) returns the marshalled OTLP export request of the record, limited as the json log (see jsonLog()) :
+// its attributes are filtered by the JsonAllowKeys option and truncated to the JsonMaxValueLength option,
+// its json log is checked by the JsonValidate option, and it is limited to the MaxJsonBytes option
+// (nil being returned if it exceeds it even without its attributes)
+func (m *CustomHandler) otlpLog(hr *handledRecord) ([]byte, error) {
+	hr = m.truncatedRecord(m.allowedRecord(hr))
+	if m.Options.JsonValidate != nil {
+		if err := m.validateJson(m.jsonData(hr)); err != nil {
+			return nil, err
+		}
+	}
+	marshal := m.Options.jsonMarshal()
+	build := func(hr *handledRecord) ([]byte, error) {
+		return marshal(m.otlpData(hr))
+	}
+	otlpByte, err := build(hr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal otlp log : %w", err)
+	}
+	return m.sizeLimited(hr, otlpByte, build)
+}
+
+// validateJson(data) checks the json log with the JsonValidate option, its error being reported
+func (m *CustomHandler) validateJson(data map[string]interface{}) error {
+	if m.Options.JsonValidate == nil {
+		return nil
+	}
+	if err := m.Options.JsonValidate(data); err != nil {
+		err = fmt.Errorf("invalid json log : %w", err)
+		m.reportError(err)
+		return err
+	}
+	return nil
+}
+
+// sizeLimited(hr, payload, build) returns the payload of the record if it doesn't exceed the MaxJsonBytes option,
+// else the payload built by build with a TRUNCATED_KEY attribute instead of the attributes of the record,
+// or nil if it still exceeds it
+func (m *CustomHandler) sizeLimited(hr *handledRecord, payload []byte, build func(*handledRecord) ([]byte, error)) ([]byte, error) {
+	if m.Options.MaxJsonBytes <= 0 || len(payload) <= m.Options.MaxJsonBytes {
+		return payload, nil
+	}
+	truncated := *hr
+	truncated.attrs = []handledAttr{{Attr: slog.Bool(TRUNCATED_KEY, true)}}
+	payload, err := build(&truncated)
+	if err != nil || len(payload) > m.Options.MaxJsonBytes {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// truncatedRecord(hr) returns the record with its string values longer than the JsonMaxValueLength option
+// truncated (see truncateValues()), or the record itself if the option is not set
+func (m *CustomHandler) truncatedRecord(hr *handledRecord) *handledRecord {
+	if m.Options.JsonMaxValueLength <= 0 {
+		return hr
+	}
+	truncated := *hr
+	truncated.attrs = make([]handledAttr, 0, len(hr.attrs))
+	for _, attr := range hr.attrs {
+		for _, a := range truncateValues(attr.Attr, m.Options.JsonMaxValueLength) {
+			truncated.attrs = append(truncated.attrs, handledAttr{groups: attr.groups, prefix: attr.prefix, record: attr.record, Attr: a})
+		}
+	}
+	return &truncated
+}
+
+// truncateValues(a, max) returns the attribute with its string value truncated to max bytes
+// (on a character boundary, followed by "…"), followed by a "<key>_truncated" attribute,
+// or the attribute itself if its value isn't longer. The members of a group are truncated
+func truncateValues(a slog.Attr, max int) []slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		if len(a.Value.String()) > max {
+			return []slog.Attr{
+				slog.String(a.Key, truncateUTF8(a.Value.String(), max)+"…"),
+				slog.Bool(a.Key+"_truncated", true),
+			}
+		}
+	case slog.KindGroup:
+		members := make([]slog.Attr, 0, len(a.Value.Group()))
+		for _, member := range a.Value.Group() {
+			members = append(members, truncateValues(member, max)...)
+		}
+		return []slog.Attr{{Key: a.Key, Value: slog.GroupValue(members...)}}
+	}
+	return []slog.Attr{a}
+}
+
+// allowedRecord(hr) returns the record with only the attributes of the JsonAllowKeys option,
+// or the record itself if the option is empty
+func (m *CustomHandler) allowedRecord(hr *handledRecord) *handledRecord {
+	if len(m.Options.JsonAllowKeys) == 0 {
+		return hr
+	}
+	allowed := *hr
+	allowed.attrs = make([]handledAttr, 0, len(hr.attrs))
+	for _, attr := range hr.attrs {
+		if a, ok := m.allowedAttr(attr.prefix+attr.Key, attr.Attr); ok {
+			attr.Attr = a
+			allowed.attrs = append(allowed.attrs, attr)
+		}
+	}
+	return &allowed
+}
+
+// allowedAttr(path, a) returns the attribute if its dotted path is in the JsonAllowKeys option.
+// A group which isn't allowed is returned with its allowed members only, if any
+func (m *CustomHandler) allowedAttr(path string, a slog.Attr) (slog.Attr, bool) {
+	if slices.Contains(m.Options.JsonAllowKeys, path) {
+		return a, true
+	}
+	if a.Value.Kind() != slog.KindGroup {
+		return a, false
+	}
+	members := m.allowedMembers(path, a.Value.Group())
+	if len(members) == 0 {
+		return a, false
+	}
+	return slog.Attr{Key: a.Key, Value: slog.GroupValue(members...)}, true
+}
+
+// allowedMembers(path, members) returns the allowed members of the group of the dotted path,
+// the members of the nested groups without key being inlined
+func (m *CustomHandler) allowedMembers(path string, members []slog.Attr) []slog.Attr {
+	allowed := []slog.Attr{}
+	for _, member := range members {
+		if member.Key == "" && member.Value.Kind() == slog.KindGroup {
+			allowed = append(allowed, m.allowedMembers(path, member.Value.Group())...)
+		} else if a, ok := m.allowedAttr(path+"."+member.Key, member); ok {
+			allowed = append(allowed, a)
+		}
+	}
+	return allowed
+}
+
+// reportError(err) reports an internal error to the InternalErrorHandler option (or on os.Stderr),
+// unless another error was reported less than InternalErrorInterval ago.
+// The rate limit is shared by the handler and the handlers derived from it
+func (m *CustomHandler) reportError(err error) {
+	interval := m.Options.InternalErrorInterval
+	if interval <= 0 {
+		interval = DEFAULT_INTERNAL_ERROR_INTERVAL
+	}
+	now := time.Now().UnixNano()
+	last := m.shared.lastError.Load()
+	if last != 0 && now-last < int64(interval) {
+		return
+	}
+	//only one of the concurrent errors is reported
+	if !m.shared.lastError.CompareAndSwap(last, now) {
+		return
+	}
+
+	if m.Options.InternalErrorHandler != nil {
+		m.Options.InternalErrorHandler(err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "customsloglogger : %s\n", err)
+}
+
+// onRecord(ctx, r) calls the OnRecord hook, or defers it until unlockSinks() if a CustomLogger locks the handler
+func (m *CustomHandler) onRecord(ctx context.Context, r slog.Record) {
+	if m.deferHooks {
+		m.pendingHooks = append(m.pendingHooks, func() { m.callOnRecord(ctx, r) })
+		return
+	}
+	m.callOnRecord(ctx, r)
+}
+
+// callOnRecord(ctx, r) calls the OnRecord hook, recovering its potential panic
+func (m *CustomHandler) callOnRecord(ctx context.Context, r slog.Record) {
+	defer func() {
+		if p := recover(); p != nil {
+			m.reportError(fmt.Errorf("panic in OnRecord hook : %v", p))
+		}
+	}()
+	m.Options.OnRecord(ctx, r)
+}
+
+// jsonData(hr) returns the json log of the record : the time, level and message of the record,
+// its potential source and component, and its attributes nested in their groups
+func (m *CustomHandler) jsonData(hr *handledRecord) map[string]interface{} {
+	return m.payloadData(hr, m.jsonValue, m.Options.JsonFieldNames.withDefaults())
+}
+
+// payloadData(hr, value, names) returns the json log of the record (see jsonData()),
+// its attribute values being represented by value and its envelope fields named by names
+func (m *CustomHandler) payloadData(hr *handledRecord, value func(slog.Value) interface{}, names JsonFieldNames) map[string]interface{} {
+	//pre-sizing the map for the envelope fields and the root attributes
+	jsonData := m.envelopeData(hr, len(hr.attrs)+6, names)
+
+	//the source is structured like the source of the slog.JSONHandler,
+	//so that the logs can be indexed by file or line
+	if hr.source != "" {
+		jsonData[names.Source] = map[string]interface{}{
+			"function": hr.frame.Function,
+			"file":     hr.frame.File,
+			"line":     hr.frame.Line,
+		}
+	}
+
+	if m.Component != "" {
+		jsonData[COMPONENT_KEY] = m.Component
+	}
+
+	if m.Options.AddScope && m.GroupName != "" {
+		jsonData[SCOPE_KEY] = m.GroupName
+	}
+
+	reserved := make([]string, 0, len(jsonData))
+	for key := range jsonData {
+		reserved = append(reserved, key)
+	}
+
+	//the attributes are nested under the NestAttrsUnder object, where they can't collide with the envelope fields
+	if m.Options.NestAttrsUnder != "" {
+		if len(hr.attrs) == 0 {
+			return jsonData
+		}
+		nested := make(map[string]interface{}, len(hr.attrs))
+		for _, attr := range hr.attrs {
+			groupMap(nested, attr.groups)[attr.Key] = value(attr.Value)
+		}
+		jsonData[unreservedKey(m.Options.NestAttrsUnder, reserved)] = nested
+		return jsonData
+	}
+
+	//the attributes (or groups) whose key collides with an envelope field are renamed
+	for _, attr := range hr.attrs {
+		groups, key := attr.groups, attr.Key
+		if len(groups) == 0 {
+			key = unreservedKey(key, reserved)
+		} else if slices.Contains(reserved, groups[0]) {
+			groups = append([]string{unreservedKey(groups[0], reserved)}, groups[1:]...)
+		}
+		groupMap(jsonData, groups)[key] = value(attr.Value)
+	}
+
+	return jsonData
+}
+
+// envelopeData(hr, size, names) returns the minimal json log of the record : its time, level and message
+// named by names, in a map pre-sized for size fields
+func (m *CustomHandler) envelopeData(hr *handledRecord, size int, names JsonFieldNames) map[string]interface{} {
+	jsonData := make(map[string]interface{}, size)
+	jsonData[names.Level] = levelName(hr.Level)
+	jsonData[names.Message] = hr.Message
+	if !m.Options.NoTime {
+		jsonData[names.Time] = hr.Time.Format("2006-01-02 15:04:05")
+	}
+	return jsonData
+}
+
+// unreservedKey(key, reserved) returns the key, prefixed by "attr_" as long as it is a reserved key
+func unreservedKey(key string, reserved []string) string {
+	for slices.Contains(reserved, key) {
+		key = "attr_" + key
+	}
+	return key
+}
+
+// textWriter(level) returns the io.Writer on which the text logs of the level are written :
+// the writer of the level in the LevelWriters option, or the TextWriter
+func (m *CustomHandler) textWriter(level slog.Level) io.Writer {
+	if w, ok := m.Options.LevelWriters[level]; ok {
+		return w
+	}
+	return m.TextWriter
+}
+
+// Close() waits for the json logs still being sent to JsonLogURL by the handler
+// and by the handlers derived from it (the sending of a json log continues after the JsonTimeout,
+// in background). It returns the context error if the context is done before.
+// A program should close its handler before exiting, to not lose its last json logs :
+// the json logs handled once it is closed are not sent (but still written on the JsonWriter)
+func (m *CustomHandler) Close(ctx context.Context) error {
+	m.shared.closeMu.Lock()
+	m.shared.closed = true
+	m.shared.closeMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.shared.deliveries.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Sync() flushes the writers of the handler (TextWriter, LevelWriters and JsonWriter)
+// implementing a Sync() error method (like *os.File) or a Flush() error method (like *bufio.Writer),
+// then waits for the json logs still being sent, at most for the JsonTimeout.
+// The errors are joined. Syncing a terminal or a pipe (e.g. os.Stderr) is not an error
+func (m *CustomHandler) Sync() error {
+	writers := []io.Writer{m.TextWriter, m.Options.JsonWriter}
+	for _, w := range m.Options.LevelWriters {
+		writers = append(writers, w)
+	}
+
+	errs := []error{}
+	for _, w := range writers {
+		var err error
+		switch s := w.(type) {
+		case interface{ Sync() error }:
+			err = s.Sync()
+		case interface{ Flush() error }:
+			err = s.Flush()
+		}
+		if err != nil && !syncUnsupported(err) {
+			errs = append(errs, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.Options.jsonTimeout())
+	defer cancel()
+	if err := m.Close(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("json logs still being sent : %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// handledRecord is a slog.Record prepared to be logged
+type handledRecord struct {
+	slog.Record
+	//color is the color of the record level, defined when rendering a colorized text log
+	color string
+	//source is the "@file:line" source of the record in the text logs, if AddSource option is true
+	source string
+	//frame is the frame of the source of the record, if AddSource option is true
+	frame runtime.Frame
+	//attrs are all the attributes to log : attributes of the parent groups,
+	//additionnal attributes, record attributes and context attributes
+	attrs []handledAttr
+}
+
+// record() returns the prepared record as a slog.Record, its attributes nested in their groups
+func (hr *handledRecord) record() slog.Record {
+	attrs := make([]slog.Attr, 0, len(hr.attrs))
+	for _, attr := range hr.attrs {
+		attrs = nestAttr(attrs, attr.groups, attr.Attr)
+	}
+	r := slog.NewRecord(hr.Time, hr.Level, hr.Message, hr.PC)
+	r.AddAttrs(attrs...)
+	return r
+}
+
+// prepare(ctx, r) prepares the record to be logged, getting
+// its attributes (as they must be logged) and its source.
+// A record without time is timed with the Now option, the invalid UTF-8 sequences of the message
+// are replaced by the Unicode replacement character
+func (m *CustomHandler) prepare(ctx context.Context, r slog.Record) *handledRecord {
+	if r.Time.IsZero() {
+		r.Time = m.Options.now()
+	}
+	r.Message = validUTF8(r.Message)
+	msgTruncated := false
+	if m.Options.MaxMessageLength > 0 && len(r.Message) > m.Options.MaxMessageLength {
+		r.Message, msgTruncated = truncateUTF8(r.Message, m.Options.MaxMessageLength)+"…", true
+	}
+
+	//init final attrs, pre-sized for the known attributes (and the correlation id, deadline, message truncation,
+	//goroutine id, sequence number and stack trace or truncation attributes) to not grow on hot paths
+	size := len(m.staticAttrs) + len(m.AdditionnalAttrs) + r.NumAttrs() + len(m.CtxAttrsKeys) + len(m.ctxAttrs) + 6
+	for _, parent := range m.parentAttrs {
+		size += len(parent.attrs)
 	}
+	attrs := make([]handledAttr, 0, size)
 
-	//init final text attrs
-	textAttrs := make([]string, 0)
+	//getting the static fields, at the root
+	for _, attr := range m.staticAttrs {
+		if attr = m.replaceAttr(nil, attr); attr.Equal(slog.Attr{}) {
+			continue
+		}
+		attrs = appendAttr(attrs, nil, "", attr)
+	}
+
+	//getting potential attributes of the parent groups
+	for _, parent := range m.parentAttrs {
+		prefix := groupPrefix(parent.groups)
+		for _, attr := range parent.attrs {
+			if attr = m.replaceAttr(parent.groups, attr); attr.Equal(slog.Attr{}) {
+				continue
+			}
+			attrs = appendAttr(attrs, parent.groups, prefix, attr)
+		}
+	}
 
-	//init final json attrs
-	jsonAttrs := make([]slog.Attr, 0)
+	//group path and prefix of the attributes of the current group
+	groups := m.groups()
+	prefix := groupPrefix(groups)
 
 	//getting and adding potentialy additionnal attr
 	for _, attr := range m.AdditionnalAttrs {
-		textAttrs = append(textAttrs, fmt.Sprintf("\t- %s%s : %s", groupPrefix, attr.Key, attr.Value))
-		jsonAttrs = append(jsonAttrs, attr)
+		if attr = m.replaceAttr(groups, attr); attr.Equal(slog.Attr{}) {
+			continue
+		}
+		attrs = appendAttr(attrs, groups, prefix, attr)
 	}
 
 	//getting Record attributes
+	recordStart := len(attrs)
+	noSource := false
 	r.Attrs(func(a slog.Attr) bool {
-		textAttrs = append(textAttrs, fmt.Sprintf("\t- %s%s : %s", groupPrefix, a.Key, a.Value))
-		jsonAttrs = append(jsonAttrs, a)
+		if a.Key == NO_SOURCE_KEY && a.Value.Kind() == slog.KindBool {
+			noSource = noSource || a.Value.Bool()
+			return true
+		}
+		if a = m.replaceAttr(groups, a); a.Equal(slog.Attr{}) {
+			return true
+		}
+		attrs = appendAttr(attrs, groups, prefix, a)
 		return true
 	})
+	if m.Options.AttrOrder == AttrOrderRecordFirst {
+		for i := recordStart; i < len(attrs); i++ {
+			attrs[i].record = true
+		}
+	}
 
 	//getting potential context attributes
 	for _, attr := range m.CtxAttrsKeys {
@@ -227,106 +1744,570 @@ func (m *CustomHandler) Handle(ctx context.Context, r slog.Record) error {
 				continue
 			}
 		}
-		value := fmt.Sprintf("%s", v)
-		textAttrs = append(textAttrs, fmt.Sprintf("\t- %s%s : %s", groupPrefix, attr, value))
-		jsonAttrs = append(jsonAttrs, slog.String(string(attr), value))
+		a := m.replaceAttr(groups, slog.String(string(attr), fmt.Sprintf("%s", v)))
+		if a.Equal(slog.Attr{}) {
+			continue
+		}
+		attrs = appendAttr(attrs, groups, prefix, a)
+	}
+
+	//getting potential renamed context attributes
+	for _, attr := range m.ctxAttrs {
+		v := ctx.Value(attr.key)
+		if key, ok := attr.key.(string); ok && v == nil {
+			v = ctx.Value(CtxKeyString(key))
+		}
+		if v == nil {
+			continue
+		}
+		if a := m.replaceAttr(groups, slog.Any(attr.name, v)); !a.Equal(slog.Attr{}) {
+			attrs = appendAttr(attrs, groups, prefix, a)
+		}
+	}
+
+	//getting potential attributes of the context extractors
+	for _, extractor := range m.Options.CtxExtractors {
+		for _, a := range extractor(ctx) {
+			if a = m.replaceAttr(groups, a); a.Equal(slog.Attr{}) {
+				continue
+			}
+			attrs = appendAttr(attrs, groups, prefix, a)
+		}
 	}
 
-	//concat output string
-	textAttrsValues := ""
-	if len(textAttrs) != 0 {
-		textAttrsValues = fmt.Sprintf("\n%s", strings.Join(textAttrs, "\n"))
+	//getting the correlation id, at the root of the attributes
+	if m.Options.GenerateCorrelationID {
+		if a := m.replaceAttr(nil, slog.String(m.Options.correlationIDKey(), m.correlationID(ctx))); !a.Equal(slog.Attr{}) {
+			attrs = appendAttr(attrs, nil, "", a)
+		}
 	}
 
-	// getting source key
-	source := ""
-	if m.Options.AddSource {
-		i := 0
-		for {
-			if _, file, line, ok := runtime.Caller(i); ok {
-				if filepath.Base(file) != "logger.go" {
-					source = fmt.Sprintf("@%s:%d", filepath.Base(file), line)
-					break
-				}
-				i++
-
-			} else {
-				fmt.Println(i, "not ok")
-				break
+	//getting the time remaining before the deadline of the context, at the root of the attributes
+	if m.Options.AddContextDeadline {
+		if deadline, ok := ctx.Deadline(); ok {
+			if a := m.replaceAttr(nil, slog.Duration(DEADLINE_REMAINING_KEY, time.Until(deadline))); !a.Equal(slog.Attr{}) {
+				attrs = appendAttr(attrs, nil, "", a)
 			}
 		}
 	}
 
-	//final display if logText is true
-	if m.logText {
-		fmt.Fprintln(
-			m.TextWriter,
-			colorize(color, fmt.Sprintf("===============%s================\n", r.Level.String()), m.Options.ColorizeLogs),
-			colorize(color, r.Message, m.Options.ColorizeLogs),
-			colorize(COLOR_DARKGRAY, fmt.Sprintf("\n %s %s", r.Time.Format(time.DateTime), source), m.Options.ColorizeLogs),
-			textAttrsValues,
-			colorize(color, "\n====================================", m.Options.ColorizeLogs),
-		)
+	//flagging the truncated message, at the root of the attributes
+	if msgTruncated {
+		if a := m.replaceAttr(nil, slog.Bool(MESSAGE_TRUNCATED_KEY, true)); !a.Equal(slog.Attr{}) {
+			attrs = appendAttr(attrs, nil, "", a)
+		}
 	}
 
-	//sending to log microservice if option enables it
-	if m.Options.JsonLogURL != "" && m.logJson {
-		ch := make(chan int)
+	//getting the id of the logging goroutine, at the root of the attributes
+	if m.Options.AddGoroutineID {
+		if a := m.replaceAttr(nil, slog.Uint64(GOROUTINE_KEY, goroutineID())); !a.Equal(slog.Attr{}) {
+			attrs = appendAttr(attrs, nil, "", a)
+		}
+	}
 
-		jsonData := map[string]interface{}{
-			"time":  r.Time.Format("2006-01-02 15:04:05"),
-			"level": r.Level.String(),
-			"msg":   r.Message,
+	//numbering the record, at the root of the attributes
+	if m.Options.AddSequence {
+		if a := m.replaceAttr(nil, slog.Uint64(SEQUENCE_KEY, m.shared.seq.Add(1))); !a.Equal(slog.Attr{}) {
+			attrs = appendAttr(attrs, nil, "", a)
 		}
+	}
 
-		if source != "" {
-			jsonData["source"] = source
+	//getting the stack trace for the records of at least StacktraceLevel, at the root of the attributes
+	if m.Options.StacktraceLevel != nil && r.Level >= m.Options.StacktraceLevel.Level() {
+		if a := m.replaceAttr(nil, slog.String(STACKTRACE_KEY, callerStacktrace())); !a.Equal(slog.Attr{}) {
+			attrs = appendAttr(attrs, nil, "", a)
 		}
+	}
 
-		if m.GroupName != "" {
-			groupMap := make(map[string]string)
-			for _, attr := range jsonAttrs {
-				groupMap[attr.Key] = attr.Value.String()
-				jsonData[m.GroupName] = groupMap
-			}
-		} else {
-			for _, attr := range jsonAttrs {
-				jsonData[attr.Key] = attr.Value.String()
+	//keeping only the last attribute of each key, like slog does
+	attrs = dedupeAttrs(attrs)
+
+	//moving the record attributes before the additionnal attributes
+	if m.Options.AttrOrder == AttrOrderRecordFirst {
+		slices.SortStableFunc(attrs, func(a, b handledAttr) int {
+			if a.record == b.record {
+				return 0
+			} else if a.record {
+				return -1
 			}
+			return 1
+		})
+	}
+
+	//sorting the attributes by key for deterministic logs
+	if m.Options.SortAttrs {
+		slices.SortStableFunc(attrs, func(a, b handledAttr) int {
+			return strings.Compare(a.prefix+a.Key, b.prefix+b.Key)
+		})
+	}
+
+	//truncating the attributes beyond MaxAttrs
+	if m.Options.MaxAttrs > 0 && len(attrs) > m.Options.MaxAttrs {
+		attrs = append(attrs[:m.Options.MaxAttrs], handledAttr{Attr: slog.Bool(TRUNCATED_KEY, true)})
+	}
+
+	// getting source key
+	source := ""
+	var frame runtime.Frame
+	if m.Options.AddSource && !noSource {
+		var ok bool
+		if frame, ok = callerFrame(); ok {
+			source = m.sourceText(frame)
+		}
+	}
+
+	return &handledRecord{Record: r, source: source, frame: frame, attrs: attrs}
+}
+
+// FormatRecord() returns the text log of the record, exactly as the Handle() method
+// writes it on the TextWriter (depending on the Format and ColorizeLogs options).
+// It can be used to embed a log in an email alert or in a test assertion
+func (m *CustomHandler) FormatRecord(ctx context.Context, r slog.Record) (string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	buf := newBuffer()
+	defer freeBuffer(buf)
+	if err := m.writeText(buf, m.prepare(ctx, r)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// sendJson(ctx, method, url, contentType, jsonByte) sends the json (or MessagePack) log to the url (JsonLogURL or OTLPEndpoint)
+// with the JsonHeaders, gzip compressed if the JsonGzip option is enabled and the log is big enough.
+// The sending is "timed out" after the JsonTimeout option, or waited for with the SyncJson option
+// With the JsonDryRun option, the json log is written on the JsonDryRunWriter instead of being sent
+func (m *CustomHandler) sendJson(ctx context.Context, method, url, contentType string, jsonByte []byte) (err error) {
+	//the json logs which couldn't be sent (or delivered with SyncJson) are failed
+	defer func() {
+		if err != nil {
+			m.shared.failed.Add(1)
 		}
+	}()
 
-		jsonByte, err := json.Marshal(jsonData)
+	//writing the payload instead of sending it in dry run
+	if m.Options.JsonDryRun {
+		m.shared.writeMu.Lock()
+		_, err := fmt.Fprintf(m.Options.jsonDryRunWriter(), "%s %s %s\n", method, url, jsonByte)
+		m.shared.writeMu.Unlock()
 		if err != nil {
-			return fmt.Errorf("unable to parse json request")
+			err = fmt.Errorf("unable to write dry run json log : %w", err)
+			m.reportError(err)
 		}
-		if req, err := http.NewRequest("POST", m.Options.JsonLogURL, bytes.NewReader(jsonByte)); err != nil {
-			return fmt.Errorf("unable to create http request to send json log")
-		} else {
-			req.Header.Set("Content-Type", "application/json")
+		return err
+	}
+
+	body := jsonByte
+	compressed := false
+	if m.Options.JsonGzip && len(jsonByte) >= m.Options.jsonGzipMinSize() {
+		buf := &bytes.Buffer{}
+		zw := gzip.NewWriter(buf)
+		if _, err := zw.Write(jsonByte); err != nil {
+			return fmt.Errorf("unable to compress json log : %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("unable to compress json log : %w", err)
+		}
+		body = buf.Bytes()
+		compressed = true
+	}
+
+	//a synchronous delivery is bounded by the JsonTimeout, a background one outlives the logging call :
+	//it isn't canceled with the context of the caller (whose values are kept), the caller waiting for it at most the JsonTimeout
+	if m.Options.SyncJson {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.Options.jsonTimeout())
+		defer cancel()
+	} else {
+		ctx = context.WithoutCancel(ctx)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("unable to create http request to send json log")
+	}
+	for key, values := range m.Options.JsonHeaders {
+		req.Header[key] = slices.Clone(values)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	if m.Options.SyncJson {
+		return m.deliverJson(req)
+	}
+
+	if !m.shared.startDelivery() {
+		err := fmt.Errorf("handler closed, json log not sent")
+		m.reportError(err)
+		return err
+	}
+	ch := make(chan int, 1)
+	go func() {
+		defer func() {
+			m.shared.deliveries.Done()
+			ch <- 1
+		}()
+		if err := m.deliverJson(req); err != nil {
+			m.shared.failed.Add(1)
+		}
+	}()
+
+	select {
+	case <-ch:
+	case <-time.After(m.Options.jsonTimeout()):
+		m.shared.timedOut.Add(1)
+	}
+	return nil
+}
+
+// startDelivery() registers a background delivery of a json log, waited by Close(),
+// unless the handler is closed
+func (s *handlerShared) startDelivery() bool {
+	s.closeMu.RLock()
+	defer s.closeMu.RUnlock()
+	if s.closed {
+		return false
+	}
+	s.deliveries.Add(1)
+	return true
+}
+
+// deliverJson(req) performs the request sending a json log and counts its delivery,
+// or reports the error if it failed
+func (m *CustomHandler) deliverJson(req *http.Request) error {
+	resp, err := m.Options.jsonClient().Do(req)
+	if err != nil {
+		err = fmt.Errorf("error while sending to log service : %w", err)
+	} else {
+		//draining the body, so that the connection is reused
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			err = fmt.Errorf("log service responded with status %s", resp.Status)
+		}
+	}
+	if err != nil {
+		m.reportError(err)
+		return err
+	}
+	m.shared.delivered.Add(1)
+	return nil
+}
+
+// packageDir is the directory of the package source files,
+// used to skip the package frames when looking for the source of a log
+var packageDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// isInternalFrame(frame) checks if the frame is a frame of this package, of the standard log
+// and log/slog packages or of the runtime (e.g. the panic frames of a log in a recover)
+func isInternalFrame(frame runtime.Frame) bool {
+	return (filepath.Dir(frame.File) == packageDir && !strings.HasSuffix(frame.File, "_test.go")) ||
+		strings.HasPrefix(frame.Function, "log/slog.") ||
+		strings.HasPrefix(frame.Function, "log.") ||
+		strings.HasPrefix(frame.Function, "runtime.")
+}
+
+// callerFrame() returns the frame of the source of the log, i.e. the first caller
+// outside of this package and of the standard log and log/slog packages
+func callerFrame() (runtime.Frame, bool) {
+	var pcs [32]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs[:])])
+	for {
+		frame, more := frames.Next()
+		if !isInternalFrame(frame) {
+			return frame, true
+		}
+		if !more {
+			return runtime.Frame{}, false
+		}
+	}
+}
+
+// sourceText(frame) returns the source of the log in the text logs : "@file:line",
+// or "@pkg.Func (file:line)" if the SourceFunction option is true
+func (m *CustomHandler) sourceText(frame runtime.Frame) string {
+	if m.Options.SourceFunction {
+		function := frame.Function[strings.LastIndexByte(frame.Function, '/')+1:]
+		return fmt.Sprintf("@%s (%s:%d)", function, filepath.Base(frame.File), frame.Line)
+	}
+	return fmt.Sprintf("@%s:%d", filepath.Base(frame.File), frame.Line)
+}
 
-			go func() {
-				defer func() {
-					ch <- 1
-				}()
+// goroutineID() returns the id of the current goroutine, read in the header of its stack trace
+// ("goroutine 42 [running]:"), or 0 if it can't be read
+func goroutineID() uint64 {
+	var stack [64]byte
+	header := stack[:runtime.Stack(stack[:], false)]
+	header, _ = bytes.CutPrefix(header, []byte("goroutine "))
+	if i := bytes.IndexByte(header, ' '); i > 0 {
+		header = header[:i]
+	}
+	id, err := strconv.ParseUint(string(header), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
 
-				client := http.Client{}
-				_, err := client.Do(req)
-				if err != nil {
-					fmt.Printf("error while sending to log service : %s\n", err)
-				}
-			}()
+// linkedSource(hr, colorized) returns the source of the record in the colorized text logs,
+// wrapped in an OSC 8 hyperlink to the SourceURLTemplate with the SourceHyperlink option,
+// or the plain source otherwise
+func (m *CustomHandler) linkedSource(hr *handledRecord, colorized bool) string {
+	if !colorized || !m.Options.SourceHyperlink || hr.source == "" {
+		return hr.source
+	}
+	url := strings.NewReplacer("{path}", filepath.ToSlash(hr.frame.File), "{line}", strconv.Itoa(hr.frame.Line)).
+		Replace(m.Options.sourceURLTemplate())
+	return "\033]8;;" + url + "\033\\" + hr.source + "\033]8;;\033\\"
+}
 
+// callerStacktrace() returns the stack trace of the log, without the frames of this package
+// and of the standard log and log/slog packages. Each frame is rendered as
+// the function name followed by a tab indented "file:line" line
+func callerStacktrace() string {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	trace := strings.Builder{}
+	for {
+		frame, more := frames.Next()
+		if !isInternalFrame(frame) {
+			if trace.Len() != 0 {
+				trace.WriteByte('\n')
+			}
+			fmt.Fprintf(&trace, "%s\n\t%s:%d", frame.Function, frame.File, frame.Line)
 		}
+		if !more {
+			return trace.String()
+		}
+	}
+}
 
-		select {
-		case <-ch:
-		case <-time.After(1 * time.Second):
+// groupMap(data, groups) returns the map of the json data corresponding to the group path,
+// creating the nested maps if needed
+func groupMap(data map[string]interface{}, groups []string) map[string]interface{} {
+	for _, group := range groups {
+		nested, ok := data[group].(map[string]interface{})
+		if !ok {
+			nested = make(map[string]interface{})
+			data[group] = nested
 		}
+		data = nested
+	}
+	return data
+}
+
+// writeText(buf, hr) renders in buf the text log of the record,
+// depending on the Format option
+func (m *CustomHandler) writeText(buf *bytes.Buffer, hr *handledRecord) error {
+	if m.Options.ColorizeLogs {
+		hr.color = m.Options.ColorPalette.levelColor(hr.Level)
 	}
 
+	switch m.Options.Format {
+	case FormatCompact:
+		m.writeCompact(buf, hr)
+	case FormatJSON:
+		jsonByte, err := m.Options.jsonMarshal()(m.jsonData(hr))
+		if err != nil {
+			return fmt.Errorf("unable to render json log : %w", err)
+		}
+		buf.Write(jsonByte)
+		buf.WriteByte('\n')
+	case FormatCEF:
+		m.writeCEF(buf, hr)
+	case FormatGCP:
+		return m.writeGCP(buf, hr)
+	default:
+		m.writeBanner(buf, hr)
+	}
 	return nil
 }
 
+// writeCompact(buf, hr) renders in buf the text log of the record
+// on a single line : the level, the time, the source, the message then the attributes as key=value
+func (m *CustomHandler) writeCompact(buf *bytes.Buffer, hr *handledRecord) {
+	colorized := m.Options.ColorizeLogs
+
+	colorize(buf, hr.color, colorized, levelName(hr.Level))
+	buf.WriteByte(' ')
+	if m.Component != "" {
+		colorize(buf, hr.color, colorized, "[", m.Component, "] ")
+	}
+	if !m.Options.NoTime {
+		colorize(buf, m.Options.ColorPalette.mutedColor(), colorized, hr.Time.Format(time.DateTime))
+		buf.WriteByte(' ')
+	}
+	if hr.source != "" {
+		colorize(buf, m.Options.ColorPalette.mutedColor(), colorized, m.linkedSource(hr, colorized))
+		buf.WriteByte(' ')
+	}
+	colorize(buf, hr.color, colorized, indentMessage(hr.Message, "\t"))
+	for _, attr := range hr.attrs {
+		m.writeCompactAttr(buf, attr.prefix, attr.Attr)
+	}
+	buf.WriteByte('\n')
+}
+
+// indentMessage(msg, indent) returns the message with its continuation lines indented,
+// so that a multi-line message (e.g. a SQL statement) stays aligned in the text logs
+func indentMessage(msg, indent string) string {
+	if !strings.Contains(msg, "\n") {
+		return msg
+	}
+	return strings.ReplaceAll(strings.TrimRight(msg, "\n"), "\n", "\n"+indent)
+}
+
+// writeCompactAttr(buf, prefix, a) renders in buf an attribute of a compact log as key=value.
+// The members of a group are rendered with the dotted group key as prefix
+func (m *CustomHandler) writeCompactAttr(buf *bytes.Buffer, prefix string, a slog.Attr) {
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix = prefix + a.Key + "."
+		}
+		for _, member := range a.Value.Group() {
+			m.writeCompactAttr(buf, prefix, member)
+		}
+		return
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(prefix)
+	buf.WriteString(a.Key)
+	buf.WriteByte('=')
+	m.writeValue(buf, a, quoteIfNeeded(m.textValue(a.Value)))
+}
+
+// writeValue(buf, a, value) renders in buf the value of the attribute, in a colorized log
+// in the ErrorValue color for an error attribute or in the color of its ValueColorRules
+func (m *CustomHandler) writeValue(buf *bytes.Buffer, a slog.Attr, value string) {
+	if m.Options.ColorizeLogs {
+		if m.isErrorAttr(a) {
+			colorize(buf, m.Options.ColorPalette.errorValueColor(), true, value)
+			return
+		}
+		if color, ok := valueColor(m.Options.ValueColorRules, a); ok {
+			colorize(buf, color, true, value)
+			return
+		}
+	}
+	buf.WriteString(value)
+}
+
+// isErrorAttr(a) returns true if the attribute holds an error or if its key is one of the ErrorKeys option
+func (m *CustomHandler) isErrorAttr(a slog.Attr) bool {
+	if _, ok := errorValue(a.Value); ok {
+		return true
+	}
+	keys := m.Options.ErrorKeys
+	if keys == nil {
+		keys = DEFAULT_ERROR_KEYS
+	}
+	for _, key := range keys {
+		if strings.EqualFold(key, a.Key) {
+			return true
+		}
+	}
+	return false
+}
+
+// quoteIfNeeded(v) returns v quoted if it is empty or contains spaces, quotes, equal signs
+// or non printable characters, so that a compact log stays on one parsable line
+func quoteIfNeeded(v string) string {
+	if v == "" {
+		return `""`
+	}
+	for _, c := range v {
+		if c == ' ' || c == '"' || c == '=' || !unicode.IsPrint(c) {
+			return strconv.Quote(v)
+		}
+	}
+	return v
+}
+
+// writeBanner(buf, hr) renders in buf the text log of the record :
+// a banner with the level, the message, the time and the source, then the attributes
+func (m *CustomHandler) writeBanner(buf *bytes.Buffer, hr *handledRecord) {
+	colorized := m.Options.ColorizeLogs
+
+	//the header is padded to the banner width, with at least one separator on each side of the label
+	width := m.Options.bannerWidth()
+	level := levelName(hr.Level)
+	labelLength := utf8.RuneCountInString(level)
+	if m.Component != "" {
+		labelLength += utf8.RuneCountInString(m.Component) + 3
+	}
+	padding := max(width-labelLength, 2)
+	if m.Component != "" {
+		colorize(buf, hr.color, colorized, separator(padding/2), level, " [", m.Component, "]", separator(padding-padding/2), "\n")
+	} else {
+		colorize(buf, hr.color, colorized, separator(padding/2), level, separator(padding-padding/2), "\n")
+	}
+	buf.WriteByte(' ')
+	colorize(buf, hr.color, colorized, indentMessage(hr.Message, " "))
+	buf.WriteByte(' ')
+	if !m.Options.NoTime {
+		colorize(buf, m.Options.ColorPalette.mutedColor(), colorized, "\n ", hr.Time.Format(time.DateTime), " ", m.linkedSource(hr, colorized))
+	} else if hr.source != "" {
+		colorize(buf, m.Options.ColorPalette.mutedColor(), colorized, "\n ", m.linkedSource(hr, colorized))
+	}
+	buf.WriteByte(' ')
+	for _, attr := range hr.attrs {
+		m.writeBannerAttr(buf, "", attr.prefix, attr.Attr)
+	}
+	buf.WriteByte(' ')
+	colorize(buf, hr.color, colorized, "\n", separator(width))
+	buf.WriteByte('\n')
+}
+
+// separators is a line of separators, sliced to render the banners without allocation
+const separators = "================================================================================"
+
+// separator(n) returns a line of n separators
+func separator(n int) string {
+	if n <= len(separators) {
+		return separators[:n]
+	}
+	return strings.Repeat("=", n)
+}
+
+// writeBannerAttr(buf, indent, prefix, a) renders in buf an attribute of a banner log
+// on its own line as "AttrPrefix key AttrSeparator value" (default "\t- key : value").
+// The members of a group are rendered on the following lines, indented once more
+// by the leading spaces or tabs of the AttrPrefix
+func (m *CustomHandler) writeBannerAttr(buf *bytes.Buffer, indent, prefix string, a slog.Attr) {
+	if a.Value.Kind() == slog.KindGroup && a.Key == "" {
+		for _, member := range a.Value.Group() {
+			m.writeBannerAttr(buf, indent, prefix, member)
+		}
+		return
+	}
+
+	attrPrefix := m.Options.attrPrefix()
+	buf.WriteByte('\n')
+	buf.WriteString(indent)
+	buf.WriteString(attrPrefix)
+	buf.WriteString(prefix)
+	buf.WriteString(a.Key)
+	if a.Value.Kind() == slog.KindGroup {
+		buf.WriteString(strings.TrimRight(m.Options.attrSeparator(), " \t"))
+		unit := attrPrefix[:len(attrPrefix)-len(strings.TrimLeft(attrPrefix, " \t"))]
+		if unit == "" {
+			unit = "\t"
+		}
+		for _, member := range a.Value.Group() {
+			m.writeBannerAttr(buf, indent+unit, "", member)
+		}
+		return
+	}
+	buf.WriteString(m.Options.attrSeparator())
+	m.writeValue(buf, a, m.textValue(a.Value))
+}
+
 // NewCustomLogger() creates a new CustomLogger.
 // A CustomLogger is a logger based on the slog package.
 // It takes the textWriter as the default io.Writer to write logs.
@@ -339,31 +2320,85 @@ func (m *CustomHandler) Handle(ctx context.Context, r slog.Record) error {
 // - for all logs with a minimum Level of slog.LevelInfo
 // - without sending json log to third party server
 func NewCustomLogger(textWriter io.Writer, options *CustomHandlerOptions) *CustomLogger {
-	internalOptions := &CustomHandlerOptions{
+	newLogger := CustomLogger{slog.New(NewCustomHandler(textWriter, options))}
+
+	return &newLogger
+
+}
+
+// NewCustomHandler() creates a new *CustomHandler, with the same defaults as NewCustomLogger().
+// It can be used with libraries expecting a slog.Handler, passed to slog.New()
+// or composed with other handlers
+func NewCustomHandler(textWriter io.Writer, options *CustomHandlerOptions) *CustomHandler {
+	internalOptions := defaultOptions()
+
+	if options != nil {
+		internalOptions = options
+	}
+
+	return &CustomHandler{
+		TextWriter:       textWriter,
+		CtxAttrsKeys:     []CtxKeyString{},
+		AdditionnalAttrs: make([]slog.Attr, 0),
+		GroupName:        "",
+		Options:          internalOptions,
+		logText:          true,
+		logJson:          true,
+		Mutex:            &sync.Mutex{},
+		shared:           &handlerShared{},
+		sampler:          newOptionsSampler(internalOptions),
+		filter:           newMessageFilter(internalOptions),
+		generatedID:      internalOptions.generatedID(),
+		staticAttrs:      internalOptions.staticAttrs(),
+	}
+}
+
+// defaultOptions() returns the options used when nil options are passed to NewCustomLogger()
+func defaultOptions() *CustomHandlerOptions {
+	return &CustomHandlerOptions{
 		ColorizeLogs: true,
 		AddSource:    true,
 		JsonLogURL:   "",
 		MinimumLevel: slog.LevelInfo,
 	}
+}
 
-	if options != nil {
-		internalOptions = options
-	}
-
-	newLogger := CustomLogger{
-		slog.New(&CustomHandler{
-			TextWriter:       textWriter,
-			CtxAttrsKeys:     []CtxKeyString{},
-			AdditionnalAttrs: make([]slog.Attr, 0),
-			GroupName:        "",
-			Options:          internalOptions,
-			logText:          true,
-			logJson:          true,
-			Mutex:            &sync.Mutex{},
-		})}
+// NewStdLogger() creates a new CustomLogger writing, by convention, the text logs of the Warn, Error
+// and Panic levels on os.Stderr and the others on os.Stdout (see LevelWriters option).
+// The LevelWriters of the options are kept, the options themselves being left unchanged.
+// If nil is passed as options, the default behavior of NewCustomLogger() is used
+func NewStdLogger(options *CustomHandlerOptions) *CustomLogger {
+	return newStdLogger(os.Stdout, os.Stderr, options)
+}
 
-	return &newLogger
+// newStdLogger(stdout, stderr, options) creates the logger of NewStdLogger() writing on stdout and stderr
+func newStdLogger(stdout, stderr io.Writer, options *CustomHandlerOptions) *CustomLogger {
+	if options == nil {
+		options = defaultOptions()
+	} else {
+		options = options.clone()
+	}
+	levelWriters := map[slog.Level]io.Writer{slog.LevelWarn: stderr, slog.LevelError: stderr, LevelPanic: stderr}
+	for level, w := range options.LevelWriters {
+		levelWriters[level] = w
+	}
+	options.LevelWriters = levelWriters
+	return NewCustomLogger(stdout, options)
+}
 
+// NewCustomLoggerE() creates a new CustomLogger like NewCustomLogger(),
+// but returns an error if the textWriter is nil or if the options are invalid
+// (see CustomHandlerOptions.Validate()) instead of failing while logging
+func NewCustomLoggerE(textWriter io.Writer, options *CustomHandlerOptions) (*CustomLogger, error) {
+	if textWriter == nil {
+		return nil, fmt.Errorf("textWriter must not be nil")
+	}
+	if options != nil {
+		if err := options.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return NewCustomLogger(textWriter, options), nil
 }
 
 // CustomLogger is a wrapper around *slog.Logger
@@ -376,15 +2411,142 @@ type CustomLogger struct {
 // based on the first one, adding a []string of keys representing
 // context keys to check in Handle() to log
 // Even if the keys are string, they are converted into CtxKeyString type
-// to avoid type collision in context
+// to avoid type collision in context.
+// A logger whose handler isn't a *CustomHandler is returned as a new *CustomLogger of the same handler
 func (c *CustomLogger) WithCtxAttrsKeys(keys []string) *CustomLogger {
-	newHandler := c.Handler()
+	h := c.Handler()
+	if h == nil {
+		return &CustomLogger{c.Logger}
+	}
+	newHandler := h.Clone()
 	for _, key := range keys {
 		newHandler.CtxAttrsKeys = append(newHandler.CtxAttrsKeys, CtxKeyString(key))
 	}
 	return &CustomLogger{slog.New(newHandler)}
 }
 
+// WithCtxAttr(key, attrName) returns a new *CustomLogger based on the first one, logging the value
+// of the context key (of any type, e.g. the unexported key type of a middleware) as the attrName attribute,
+// e.g. WithCtxAttr("x-request-id", "request_id"). A string key also matches the CtxKeyString key.
+// A logger whose handler isn't a *CustomHandler is returned as a new *CustomLogger of the same handler
+func (c *CustomLogger) WithCtxAttr(key any, attrName string) *CustomLogger {
+	h := c.Handler()
+	if h == nil {
+		return &CustomLogger{c.Logger}
+	}
+	newHandler := h.Clone()
+	newHandler.ctxAttrs = append(newHandler.ctxAttrs, ctxAttr{key: key, name: attrName})
+	return &CustomLogger{slog.New(newHandler)}
+}
+
+// WithSampling(initial, thereafter) returns a new *CustomLogger based on the first one, sampling its own logs
+// (e.g. the logger of a tight loop) : the initial first records of each level and message within a SamplerTick window
+// are logged, then every thereafter record (see SamplerInitial and SamplerThereafter options),
+// the SamplerLevels option still overriding them for its levels.
+// The sampler is isolated : it replaces the potential sampler of the first logger in the new one only,
+// the first logger and the other loggers derived from it keep logging as before.
+// A logger whose handler isn't a *CustomHandler is returned as a new *CustomLogger of the same handler
+func (c *CustomLogger) WithSampling(initial, thereafter int) *CustomLogger {
+	h := c.Handler()
+	if h == nil {
+		return &CustomLogger{c.Logger}
+	}
+	newHandler := h.Clone()
+	newHandler.Options.SamplerInitial = initial
+	newHandler.Options.SamplerThereafter = thereafter
+	newHandler.sampler = newOptionsSampler(newHandler.Options)
+	return &CustomLogger{slog.New(newHandler)}
+}
+
+// Named() returns a new *CustomLogger based on the first one, tagging its logs with
+// a component name (e.g. "db", "http", "cache") :
+// the component is shown in the text logs and logged as a COMPONENT_KEY json field.
+// Nested Named() calls are joined with a dot (e.g. logger.Named("http").Named("router")
+// tags the logs with "http.router").
+// A logger whose handler isn't a *CustomHandler is returned as a new *CustomLogger of the same handler
+func (c *CustomLogger) Named(name string) *CustomLogger {
+	h := c.Handler()
+	if h == nil {
+		return &CustomLogger{c.Logger}
+	}
+	newHandler := h.Clone()
+	if newHandler.Component != "" && name != "" {
+		newHandler.Component = newHandler.Component + "." + name
+	} else if name != "" {
+		newHandler.Component = name
+	}
+	return &CustomLogger{slog.New(newHandler)}
+}
+
+// WithWriter() returns a new *CustomLogger based on the first one (same options, groups,
+// attributes and context keys) writing its text logs on another io.Writer.
+// The writers of the LevelWriters option still take precedence for their levels.
+// A logger whose handler isn't a *CustomHandler is returned as a new *CustomLogger of the same handler
+func (c *CustomLogger) WithWriter(w io.Writer) *CustomLogger {
+	h := c.Handler()
+	if h == nil {
+		return &CustomLogger{c.Logger}
+	}
+	newHandler := h.Clone()
+	newHandler.TextWriter = w
+	return &CustomLogger{slog.New(newHandler)}
+}
+
+// SetAsDefault() makes the logger the default slog.Logger, used by the top-level functions
+// of log/slog (slog.Info()...) and by the standard log package (log.Printf()...).
+// These calls log both text and json, whatever the last TextOnly or JsonOnly method called
+// on the logger, as the default logger gets its own copy of the handler.
+// With the AddSource option, the source of their logs is their caller, as for a direct call
+func (c *CustomLogger) SetAsDefault() {
+	slog.SetDefault(c.Clone().Logger)
+}
+
+// Clone() returns a new *CustomLogger with a copy of the handler : its own mutex and TextOnly/JsonOnly routing,
+// copies of its attributes and options, so that the two loggers can be passed to two subsystems
+// without interfering. The clone still shares the writers, the statistics and the json deliveries
+// of the logger (see Close()), and logs with the same generated id.
+// A logger whose handler isn't a *CustomHandler (e.g. a MultiHandler) is returned as a new *CustomLogger of the same handler
+func (c *CustomLogger) Clone() *CustomLogger {
+	h := c.Handler()
+	if h == nil {
+		return &CustomLogger{c.Logger}
+	}
+	newHandler := h.Clone()
+	newHandler.generatedID = h.generatedID
+	return &CustomLogger{slog.New(newHandler)}
+}
+
+// Close() waits for the json logs still being sent, until the context is done.
+// Callers should defer it to not lose the last json logs when the program exits :
+//
+//	defer logger.Close(context.Background())
+func (c *CustomLogger) Close(ctx context.Context) error {
+	if closer, ok := c.Logger.Handler().(interface{ Close(context.Context) error }); ok {
+		return closer.Close(ctx)
+	}
+	return nil
+}
+
+// Sync() flushes the writers of the logger and waits for the json logs still being sent
+// (see CustomHandler.Sync()), e.g. in the shutdown hook of a framework.
+// The handlers of a MultiHandler are all synced
+func (c *CustomLogger) Sync() error {
+	if s, ok := c.Logger.Handler().(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// Stats() returns a snapshot of the statistics of the logger (see HandlerStats),
+// or zero statistics if its handler isn't a *CustomHandler
+func (c *CustomLogger) Stats() HandlerStats {
+	h := c.Handler()
+	if h == nil {
+		return HandlerStats{}
+	}
+	return h.Stats()
+}
+
 // Handler() return the *CustomHandler
 func (c *CustomLogger) Handler() *CustomHandler {
 	if h, ok := c.Logger.Handler().(*CustomHandler); ok {
@@ -393,13 +2555,31 @@ func (c *CustomLogger) Handler() *CustomHandler {
 	return nil
 }
 
+// lockSinks(logText, logJson) locks the handler and enables its sinks for the record being logged,
+// its OnRecord hooks being deferred until unlockSinks()
+func (m *CustomHandler) lockSinks(logText, logJson bool) {
+	m.Lock()
+	m.logJson = logJson
+	m.logText = logText
+	m.deferHooks = true
+}
+
+// unlockSinks() unlocks the handler locked by lockSinks(), then calls the deferred OnRecord hooks,
+// so that a hook logging with the same logger doesn't deadlock
+func (m *CustomHandler) unlockSinks() {
+	hooks := m.pendingHooks
+	m.pendingHooks, m.deferHooks = nil, false
+	m.Unlock()
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
 // log() general method for logging, called for every Methods
 func (c *CustomLogger) log(ctx context.Context, level slog.Level, msg string, logText, logJson bool, args ...any) {
 	if h := c.Handler(); h != nil {
-		h.Lock()
-		defer h.Unlock()
-		h.logJson = logJson
-		h.logText = logText
+		h.lockSinks(logText, logJson)
+		defer h.unlockSinks()
 	}
 	c.Logger.Log(ctx, level, msg, args...)
 }
@@ -479,6 +2659,31 @@ func (c *CustomLogger) Error(msg string, args ...any) {
 	c.log(context.TODO(), slog.LevelError, msg, true, true, args...)
 }
 
+// Panic() logs the message at LevelPanic (text and json logs are enable), flushes the writers and waits
+// for the json logs still being sent, at most JsonTimeout (see Sync()), then panics with the message followed by its attributes as key=value,
+// e.g. for an unrecoverable state whose stack unwinding can still be recovered
+func (c *CustomLogger) Panic(msg string, args ...any) {
+	c.log(context.TODO(), LevelPanic, msg, true, true, args...)
+	c.Sync()
+	panic(panicMessage(msg, args...))
+}
+
+// panicMessage(msg, args) returns the message followed by its attributes as key=value
+func panicMessage(msg string, args ...any) string {
+	r := slog.NewRecord(time.Time{}, LevelPanic, msg, 0)
+	r.Add(args...)
+	b := strings.Builder{}
+	b.WriteString(msg)
+	r.Attrs(func(a slog.Attr) bool {
+		b.WriteByte(' ')
+		b.WriteString(a.Key)
+		b.WriteByte('=')
+		b.WriteString(quoteIfNeeded(a.Value.Resolve().String()))
+		return true
+	})
+	return b.String()
+}
+
 // ErrorTextOnly() re-defines the method of the inner slog.Logger, text log is enable
 func (c *CustomLogger) ErrorTextOnly(msg string, args ...any) {
 	c.log(context.TODO(), slog.LevelError, msg, true, false, args...)
@@ -509,6 +2714,11 @@ func (c *CustomLogger) Debug(msg string, args ...any) {
 	c.log(context.TODO(), slog.LevelDebug, msg, true, true, args...)
 }
 
+// DebugNoSource() logs as Debug(), without computing the source of the log (see NoSource())
+func (c *CustomLogger) DebugNoSource(msg string, args ...any) {
+	c.log(context.TODO(), slog.LevelDebug, msg, true, true, append(args, NoSource())...)
+}
+
 // DebugTextOnly() re-defines the method of the inner slog.Logger, text log is enable
 func (c *CustomLogger) DebugTextOnly(msg string, args ...any) {
 	c.log(context.TODO(), slog.LevelDebug, msg, true, false, args...)
@@ -536,10 +2746,8 @@ func (c *CustomLogger) DebugContextJsonOnly(ctx context.Context, msg string, arg
 
 func (c *CustomLogger) logAttrs(ctx context.Context, level slog.Level, msg string, logText, logJson bool, attrs ...slog.Attr) {
 	if h := c.Handler(); h != nil {
-		h.Lock()
-		defer h.Unlock()
-		h.logJson = logJson
-		h.logText = logText
+		h.lockSinks(logText, logJson)
+		defer h.unlockSinks()
 	}
 	c.Logger.LogAttrs(ctx, level, msg, attrs...)
 }
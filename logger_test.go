@@ -1,15 +1,32 @@
 package customsloglogger
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"log/slog"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
+
+	"github.com/darthyoh/custom-slog-logger/customslogloggertest"
 )
 
 func logJSONServer() {
@@ -204,3 +221,2390 @@ func TestHttp(t *testing.T) {
 	wg.Wait()
 
 }
+
+func TestRedactKeys(t *testing.T) {
+	server, serverURL := customslogloggertest.NewCaptureServer()
+	defer server.Close()
+	buf := &bytes.Buffer{}
+
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{
+		JsonLogURL: serverURL,
+		RedactKeys: []string{"password", "Token", "request.authorization"},
+	}).With("token", "secret-token").WithCtxAttrsKeys([]string{"password"})
+
+	ctx := context.WithValue(context.Background(), CtxKeyString("password"), "secret-password")
+	logger.InfoContext(ctx, "redacted", "TOKEN", "secret-token-2", "user", "bob",
+		slog.Group("request", slog.String("authorization", "secret-bearer"), slog.String("path", "/")))
+
+	grouped := NewCustomLogger(buf, &CustomHandlerOptions{
+		JsonLogURL: serverURL,
+		RedactKeys: []string{"request.authorization"},
+	}).WithGroup("request")
+	grouped.Info("grouped", "authorization", "secret-grouped")
+
+	sinks := map[string]string{"text": buf.String()}
+	for i, body := range server.Bodies() {
+		sinks[fmt.Sprintf("json #%d", i)] = string(body)
+	}
+	if len(sinks) != 3 {
+		t.Fatalf("expected 2 json payloads, got %d", len(sinks)-1)
+	}
+
+	for sink, output := range sinks {
+		for _, secret := range []string{"secret-token", "secret-password", "secret-bearer", "secret-grouped"} {
+			if strings.Contains(output, secret) {
+				t.Errorf("%s output contains secret value %q :\n%s", sink, secret, output)
+			}
+		}
+		if !strings.Contains(output, REDACTED_VALUE) {
+			t.Errorf("%s output doesn't contain %q :\n%s", sink, REDACTED_VALUE, output)
+		}
+	}
+	if !strings.Contains(buf.String(), "user : bob") {
+		t.Errorf("non redacted attribute is missing from text output :\n%s", buf.String())
+	}
+}
+
+func TestReplaceAttr(t *testing.T) {
+	server, serverURL := customslogloggertest.NewCaptureServer()
+	defer server.Close()
+	buf := &bytes.Buffer{}
+
+	var receivedGroups [][]string
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{
+		JsonLogURL: serverURL,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			receivedGroups = append(receivedGroups, groups)
+			switch {
+			case a.Key == "statut_code":
+				a.Key = "status_code"
+			case a.Value.Kind() == slog.KindDuration:
+				a.Value = slog.Int64Value(a.Value.Duration().Milliseconds())
+			case a.Key == "dropped":
+				return slog.Attr{}
+			}
+			return a
+		},
+	}).WithGroup("req").With("dropped", "additionnal").WithCtxAttrsKeys([]string{"dropped"})
+
+	ctx := context.WithValue(context.Background(), CtxKeyString("dropped"), "context")
+	logger.InfoContext(ctx, "replaced", "statut_code", 7, "elapsed", 1500*time.Millisecond, "dropped", "record")
+
+	text := buf.String()
+	for _, expected := range []string{"req.status_code : 7", "req.elapsed : 1500"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("text output doesn't contain %q :\n%s", expected, text)
+		}
+	}
+	for _, unexpected := range []string{"statut_code", "dropped"} {
+		if strings.Contains(text, unexpected) {
+			t.Errorf("text output contains %q :\n%s", unexpected, text)
+		}
+	}
+
+	payloads := server.Payloads()
+	if len(payloads) != 1 {
+		t.Fatalf("expected 1 json payload, got %d", len(payloads))
+	}
+	group, ok := payloads[0]["req"].(map[string]any)
+	if !ok {
+		t.Fatalf("group req is missing from json payload : %v", payloads[0])
+	}
+	if group["status_code"] != 7.0 || group["elapsed"] != 1500.0 {
+		t.Errorf("unexpected replaced attributes in json payload : %v", group)
+	}
+	if _, ok := group["dropped"]; ok {
+		t.Errorf("dropped attribute found in json payload : %v", group)
+	}
+
+	for _, groups := range receivedGroups {
+		if !slices.Equal(groups, []string{"req"}) {
+			t.Errorf("ReplaceAttr called with groups %v, expected [req]", groups)
+		}
+	}
+}
+
+func TestHandleCanceledContext(t *testing.T) {
+	server, serverURL := customslogloggertest.NewCaptureServer()
+	defer server.Close()
+	buf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{JsonLogURL: serverURL})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	logger.InfoContext(ctx, "abandoned request")
+
+	if bodies := server.Bodies(); len(bodies) != 0 {
+		t.Errorf("expected no json log with a canceled context, got %d", len(bodies))
+	}
+
+	logger.InfoContext(context.Background(), "running request")
+	if bodies := server.Bodies(); len(bodies) != 1 {
+		t.Errorf("expected 1 json log with a running context, got %d", len(bodies))
+	}
+}
+
+// benchmarkRecord returns a record with a few attributes, used by benchmarks
+func benchmarkRecord() slog.Record {
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "benchmark message", 0)
+	r.AddAttrs(slog.String("url", "/api/v1/users"), slog.Int("status", 200), slog.Bool("cached", true))
+	return r
+}
+
+func BenchmarkHandleText(b *testing.B) {
+	handler := NewCustomLogger(io.Discard, &CustomHandlerOptions{ColorizeLogs: true}).Handler()
+	r := benchmarkRecord()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		handler.Handle(ctx, r)
+	}
+}
+
+// BenchmarkPrepareManyAttrs : preparing a record with 8 attributes and 2 additionnal attributes
+// took 6 allocations before the pre-sizing of the attributes, 2 after
+func BenchmarkPrepareManyAttrs(b *testing.B) {
+	handler := NewCustomLogger(io.Discard, &CustomHandlerOptions{}).
+		With("service", "api", "version", "1.2.0").Handler()
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "benchmark message", 0)
+	r.AddAttrs(slog.String("url", "/api/v1/users"), slog.Int("status", 200), slog.Bool("cached", true),
+		slog.String("method", "GET"), slog.Duration("elapsed", time.Millisecond), slog.String("user", "bob"),
+		slog.Int("size", 512), slog.String("remote", "127.0.0.1"))
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		handler.prepare(ctx, r)
+	}
+}
+
+// marshalUnescaped marshals like json.Marshal, without escaping the HTML characters
+func marshalUnescaped(v any) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func BenchmarkHandleJson(b *testing.B) {
+	for name, marshal := range map[string]func(any) ([]byte, error){"json.Marshal": nil, "unescaped": marshalUnescaped} {
+		b.Run(name, func(b *testing.B) {
+			handler := NewCustomLogger(io.Discard, &CustomHandlerOptions{JsonWriter: io.Discard, JsonMarshal: marshal}).Handler()
+			handler.logText = false
+			r := benchmarkRecord()
+			ctx := context.Background()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				handler.Handle(ctx, r)
+			}
+		})
+	}
+}
+
+func BenchmarkLogJsonOnly(b *testing.B) {
+	logger := NewCustomLogger(io.Discard, &CustomHandlerOptions{ColorizeLogs: true, JsonWriter: io.Discard})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.InfoJsonOnly("json only", "id", i, "user", "bob")
+	}
+}
+
+func TestHandleDisabledSinksAllocations(t *testing.T) {
+	handler := NewCustomLogger(io.Discard, &CustomHandlerOptions{ColorizeLogs: true}).Handler()
+	handler.logText = false
+	r := benchmarkRecord()
+	ctx := context.Background()
+
+	//a json only record without json sink isn't prepared at all
+	if allocs := testing.AllocsPerRun(100, func() { handler.Handle(ctx, r) }); allocs > 0 {
+		t.Errorf("handling a record without enabled sink made %.0f allocations, expected none", allocs)
+	}
+}
+
+func TestJsonMarshal(t *testing.T) {
+	calls := 0
+	marshal := func(v any) ([]byte, error) {
+		calls++
+		return marshalUnescaped(v)
+	}
+
+	buf := &bytes.Buffer{}
+	jsonBuf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatJSON, JsonWriter: jsonBuf, JsonMarshal: marshal})
+	logger.Info("html", "tag", "<b>")
+
+	if calls != 2 {
+		t.Errorf("expected the custom marshaller to be called for the text and json logs, got %d calls", calls)
+	}
+	for _, output := range []string{buf.String(), jsonBuf.String()} {
+		if !strings.Contains(output, `"tag":"<b>"`) || !strings.HasSuffix(output, "}\n") {
+			t.Errorf("expected the unescaped json line, got %q", output)
+		}
+	}
+
+	failing := NewCustomLogger(io.Discard, &CustomHandlerOptions{JsonWriter: jsonBuf, JsonMarshal: func(v any) ([]byte, error) {
+		return nil, fmt.Errorf("marshal failure")
+	}}).Handler()
+	if err := failing.Handle(context.Background(), goldenRecord(slog.LevelInfo, "failing")); err == nil {
+		t.Errorf("expected the marshal error to be returned")
+	}
+}
+
+func TestJsonMarshalFallback(t *testing.T) {
+	server, serverURL := customslogloggertest.NewCaptureServer()
+	defer server.Close()
+	buf := &bytes.Buffer{}
+	var reported []error
+	//a marshaller rejecting the logs with a "payload" attribute, as one rejecting an attribute type
+	marshal := func(v any) ([]byte, error) {
+		if data, ok := v.(map[string]interface{}); ok && data["payload"] != nil {
+			return nil, errors.New("unsupported payload")
+		}
+		return json.Marshal(v)
+	}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{
+		Format:               FormatCompact,
+		JsonLogURL:           serverURL,
+		SyncJson:             true,
+		JsonMarshal:          marshal,
+		InternalErrorHandler: func(err error) { reported = append(reported, err) },
+	})
+	logger.Warn("degraded", "payload", "unmarshallable", "id", 1)
+
+	if !strings.HasSuffix(buf.String(), " degraded payload=unmarshallable id=1\n") {
+		t.Errorf("expected the text log to be written, got %q", buf.String())
+	}
+	payloads := server.Payloads()
+	if len(payloads) != 1 {
+		t.Fatalf("expected the minimal json log to be sent, got %d payloads", len(payloads))
+	}
+	if len(payloads[0]) != 3 || payloads[0]["msg"] != "degraded" || payloads[0]["level"] != "WARN" || payloads[0]["time"] == nil {
+		t.Errorf("expected the time, level and message only, got %v", payloads[0])
+	}
+	if len(reported) != 1 || !strings.Contains(reported[0].Error(), "unsupported payload") {
+		t.Errorf("expected the marshal error to be reported, got %v", reported)
+	}
+}
+
+func TestHandleTextAllocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector makes extra allocations")
+	}
+	handler := NewCustomLogger(io.Discard, &CustomHandlerOptions{ColorizeLogs: true}).Handler()
+	r := benchmarkRecord()
+	ctx := context.Background()
+
+	//rendering a record with 3 attributes took 42 allocations before the buffer pool, 8 after
+	if allocs := testing.AllocsPerRun(100, func() { handler.Handle(ctx, r) }); allocs > 10 {
+		t.Errorf("text rendering of a record made %.0f allocations, expected at most 10", allocs)
+	}
+}
+
+var update = flag.Bool("update", false, "update the golden files of the tests")
+
+// goldenRecord returns a record with a fixed time, used by golden files tests
+func goldenRecord(level slog.Level, msg string, attrs ...slog.Attr) slog.Record {
+	r := slog.NewRecord(time.Date(2024, 5, 17, 14, 30, 0, 0, time.UTC), level, msg, 0)
+	r.AddAttrs(attrs...)
+	return r
+}
+
+// assertGolden compares the output with the testdata/<name>.golden file
+// (re)written when the tests are run with the -update flag
+func assertGolden(t *testing.T, name string, output []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, output, 0o644); err != nil {
+			t.Fatalf("unable to update golden file : %s", err)
+		}
+	}
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unable to read golden file : %s", err)
+	}
+	if !bytes.Equal(output, expected) {
+		t.Errorf("output doesn't match %s :\n%s\nexpected :\n%s", path, output, expected)
+	}
+}
+
+func TestTextFormatGolden(t *testing.T) {
+	for name, format := range map[string]TextFormat{"banner": FormatBanner, "compact": FormatCompact} {
+		t.Run(name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			for _, colorized := range []bool{false, true} {
+				handler := NewCustomLogger(buf, &CustomHandlerOptions{Format: format, ColorizeLogs: colorized}).
+					WithGroup("req").With("url", "/users").Handler()
+				handler.Handle(context.Background(), goldenRecord(slog.LevelWarn, "slow request", slog.Int("status", 200), slog.String("agent", "curl 8.0")))
+				handler.Handle(context.Background(), goldenRecord(slog.LevelError, "no attributes"))
+			}
+			assertGolden(t, name, buf.Bytes())
+		})
+	}
+}
+
+func TestAttrStyleGolden(t *testing.T) {
+	buf := &bytes.Buffer{}
+	handler := NewCustomLogger(buf, &CustomHandlerOptions{AttrPrefix: "  ", AttrSeparator: "="}).With("url", "/users").Handler()
+	handler.Handle(context.Background(), goldenRecord(slog.LevelInfo, "styled", slog.Int("status", 200),
+		slog.Group("db", slog.String("table", "users"), slog.Group("pool", slog.Int("size", 4)))))
+	assertGolden(t, "attrstyle", buf.Bytes())
+
+	options := &CustomHandlerOptions{AttrPrefix: "\n- ", AttrSeparator: ":\r"}
+	err := options.Validate()
+	if err == nil || !strings.Contains(err.Error(), "invalid AttrPrefix") || !strings.Contains(err.Error(), "invalid AttrSeparator") {
+		t.Errorf("expected the line breaks of AttrPrefix and AttrSeparator to be rejected, got %v", err)
+	}
+}
+
+func TestSortAttrsGolden(t *testing.T) {
+	inputs := [][]slog.Attr{
+		{slog.String("zone", "eu"), slog.Int("count", 3), slog.Group("db", slog.String("table", "users")), slog.String("app", "api")},
+		{slog.String("app", "api"), slog.Group("db", slog.String("table", "users")), slog.String("zone", "eu"), slog.Int("count", 3)},
+	}
+
+	outputs := make([][]byte, 0, len(inputs))
+	for _, attrs := range inputs {
+		buf := &bytes.Buffer{}
+		handler := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, SortAttrs: true}).With("version", "1.2").Handler()
+		handler.Handle(context.Background(), goldenRecord(slog.LevelInfo, "sorted", attrs...))
+		outputs = append(outputs, buf.Bytes())
+	}
+
+	if !bytes.Equal(outputs[0], outputs[1]) {
+		t.Errorf("expected the same output for differently-ordered attributes :\n%s\n%s", outputs[0], outputs[1])
+	}
+	assertGolden(t, "sorted", outputs[0])
+}
+
+func TestDottedGroupName(t *testing.T) {
+	buf := &bytes.Buffer{}
+	jsonBuf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, JsonWriter: jsonBuf}).
+		WithGroup("api.v1").With("id", 5).WithGroup("req")
+	logger.Info("dotted", "k", "v")
+
+	if !strings.HasSuffix(buf.String(), " dotted api.v1.id=5 api.v1.req.k=v\n") {
+		t.Errorf("unexpected text log %q", buf.String())
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(jsonBuf.Bytes(), &payload); err != nil {
+		t.Fatalf("invalid json log : %s", err)
+	}
+	expected := map[string]interface{}{"id": 5.0, "req": map[string]interface{}{"k": "v"}}
+	if !reflect.DeepEqual(payload["api.v1"], expected) {
+		t.Errorf("expected the dotted group as a single json object %v, got %v", expected, payload)
+	}
+	if groups := logger.Handler().State().Groups; !reflect.DeepEqual(groups, []string{"api.v1", "req"}) {
+		t.Errorf("unexpected group path %q", groups)
+	}
+}
+
+func TestWithAndWithGroupChaining(t *testing.T) {
+	tests := []struct {
+		name   string
+		derive func(l *CustomLogger) *CustomLogger
+		text   []string
+		json   map[string]any
+	}{
+		{
+			name:   "With then With",
+			derive: func(l *CustomLogger) *CustomLogger { return l.With("id", 5).With("user", "bob") },
+			text:   []string{"- id : 5", "- user : bob", "- k : v"},
+			json:   map[string]any{"id": 5.0, "user": "bob", "k": "v"},
+		},
+		{
+			name:   "WithGroup then WithGroup",
+			derive: func(l *CustomLogger) *CustomLogger { return l.WithGroup("a").WithGroup("b") },
+			text:   []string{"- a.b.k : v"},
+			json:   map[string]any{"a": map[string]any{"b": map[string]any{"k": "v"}}},
+		},
+		{
+			name:   "With then WithGroup",
+			derive: func(l *CustomLogger) *CustomLogger { return l.With("id", 5).WithGroup("req") },
+			text:   []string{"- id : 5", "- req.k : v"},
+			json:   map[string]any{"id": 5.0, "req": map[string]any{"k": "v"}},
+		},
+		{
+			name:   "WithGroup then With",
+			derive: func(l *CustomLogger) *CustomLogger { return l.WithGroup("req").With("id", 5) },
+			text:   []string{"- req.id : 5", "- req.k : v"},
+			json:   map[string]any{"req": map[string]any{"id": 5.0, "k": "v"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, serverURL := customslogloggertest.NewCaptureServer()
+			defer server.Close()
+			buf := &bytes.Buffer{}
+			base := NewCustomLogger(buf, &CustomHandlerOptions{JsonLogURL: serverURL})
+
+			test.derive(base).Info("chained", "k", "v")
+
+			for _, expected := range test.text {
+				if !strings.Contains(buf.String(), expected) {
+					t.Errorf("text output doesn't contain %q :\n%s", expected, buf.String())
+				}
+			}
+
+			payloads := server.Payloads()
+			if len(payloads) != 1 {
+				t.Fatalf("expected 1 json payload, got %d", len(payloads))
+			}
+			for _, key := range []string{"time", "level", "msg"} {
+				delete(payloads[0], key)
+			}
+			if !reflect.DeepEqual(payloads[0], test.json) {
+				t.Errorf("unexpected json attributes %v, expected %v", payloads[0], test.json)
+			}
+
+			//the base logger must not be modified by the derivation
+			buf.Reset()
+			base.Info("base")
+			if strings.Contains(buf.String(), "- ") {
+				t.Errorf("base logger output contains attributes :\n%s", buf.String())
+			}
+		})
+	}
+}
+
+func TestJsonGzip(t *testing.T) {
+	server, serverURL := customslogloggertest.NewCaptureServer()
+	defer server.Close()
+	logger := NewCustomLogger(io.Discard, &CustomHandlerOptions{
+		JsonLogURL:      serverURL,
+		JsonGzip:        true,
+		JsonGzipMinSize: 512,
+	})
+
+	large := strings.Repeat("compressible ", 100)
+	logger.Info("large log", "payload", large)
+	logger.Info("small log")
+
+	bodies, headers := server.Bodies(), server.Headers()
+	if len(bodies) != 2 {
+		t.Fatalf("expected 2 json payloads, got %d", len(bodies))
+	}
+
+	//the capture server stores the decompressed bodies, rejecting the invalid gzip ones
+	if encoding := headers[0].Get("Content-Encoding"); encoding != "gzip" {
+		t.Fatalf("expected a gzip encoded large log, got Content-Encoding %q", encoding)
+	}
+	payload := map[string]any{}
+	if err := json.Unmarshal(bodies[0], &payload); err != nil {
+		t.Fatalf("decompressed body is not valid json : %s", err)
+	}
+	if payload["msg"] != "large log" || payload["payload"] != large {
+		t.Errorf("unexpected decompressed payload : %v", payload)
+	}
+
+	if encoding := headers[1].Get("Content-Encoding"); encoding != "" {
+		t.Errorf("expected an uncompressed small log, got Content-Encoding %q", encoding)
+	}
+	if !json.Valid(bodies[1]) {
+		t.Errorf("small log body is not plain json : %q", bodies[1])
+	}
+}
+
+func TestFormatRecord(t *testing.T) {
+	for name, format := range map[string]TextFormat{"banner": FormatBanner, "compact": FormatCompact} {
+		t.Run(name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			handler := NewCustomLogger(buf, &CustomHandlerOptions{Format: format, ColorizeLogs: true}).
+				WithGroup("req").With("url", "/users").Handler()
+			r := goldenRecord(slog.LevelError, "formatted", slog.Int("status", 500))
+
+			formatted, err := handler.FormatRecord(context.Background(), r)
+			if err != nil {
+				t.Fatalf("unable to format record : %s", err)
+			}
+			if err := handler.Handle(context.Background(), r); err != nil {
+				t.Fatalf("unable to handle record : %s", err)
+			}
+			if formatted != buf.String() {
+				t.Errorf("formatted record %q differs from the written log %q", formatted, buf.String())
+			}
+		})
+	}
+}
+
+func TestLevelWriters(t *testing.T) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	logger := NewCustomLogger(stdout, &CustomHandlerOptions{
+		Format:       FormatCompact,
+		MinimumLevel: slog.LevelDebug,
+		LevelWriters: map[slog.Level]io.Writer{slog.LevelError: stderr, slog.LevelWarn: stderr},
+	}).With("id", 5)
+
+	logger.Error("error log")
+	logger.Warn("warn log")
+	logger.Info("info log")
+	logger.Debug("debug log")
+
+	for _, msg := range []string{"error log", "warn log"} {
+		if !strings.Contains(stderr.String(), msg) || strings.Contains(stdout.String(), msg) {
+			t.Errorf("expected %q only in the error writer", msg)
+		}
+	}
+	for _, msg := range []string{"info log", "debug log"} {
+		if !strings.Contains(stdout.String(), msg) || strings.Contains(stderr.String(), msg) {
+			t.Errorf("expected %q only in the default text writer", msg)
+		}
+	}
+}
+
+func TestNewStdLogger(t *testing.T) {
+	stdout, stderr, custom := &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}
+	options := &CustomHandlerOptions{
+		Format:       FormatCompact,
+		MinimumLevel: slog.LevelDebug,
+		LevelWriters: map[slog.Level]io.Writer{slog.LevelDebug: custom},
+	}
+	logger := newStdLogger(stdout, stderr, options)
+
+	logger.Error("error log")
+	logger.Warn("warn log")
+	logger.Info("info log")
+	logger.Debug("debug log")
+
+	for _, msg := range []string{"error log", "warn log"} {
+		if !strings.Contains(stderr.String(), msg) || strings.Contains(stdout.String(), msg) {
+			t.Errorf("expected %q only on stderr", msg)
+		}
+	}
+	if !strings.Contains(stdout.String(), "info log") || strings.Contains(stderr.String(), "info log") {
+		t.Errorf("expected the info log only on stdout")
+	}
+	if !strings.Contains(custom.String(), "debug log") || strings.Contains(stdout.String(), "debug log") {
+		t.Errorf("expected the debug log on the writer of the LevelWriters option")
+	}
+	if len(options.LevelWriters) != 1 {
+		t.Errorf("expected the options to be left unchanged, got %v", options.LevelWriters)
+	}
+
+	if NewStdLogger(nil).Handler().Options.LevelWriters[slog.LevelError] != os.Stderr {
+		t.Errorf("expected the error logs on os.Stderr by default")
+	}
+}
+
+func TestStacktraceLevel(t *testing.T) {
+	server, serverURL := customslogloggertest.NewCaptureServer()
+	defer server.Close()
+	buf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{
+		JsonLogURL:      serverURL,
+		StacktraceLevel: slog.LevelError,
+	}).WithGroup("req")
+
+	logger.Warn("no stack trace")
+	if strings.Contains(buf.String(), STACKTRACE_KEY) {
+		t.Errorf("unexpected stack trace for a Warn log :\n%s", buf.String())
+	}
+
+	buf.Reset()
+	logger.Error("stack trace")
+	for _, expected := range []string{"- stacktrace : ", "custom-slog-logger.TestStacktraceLevel", "logger_test.go:"} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("text output doesn't contain %q :\n%s", expected, buf.String())
+		}
+	}
+
+	payloads := server.Payloads()
+	if len(payloads) != 2 {
+		t.Fatalf("expected 2 json payloads, got %d", len(payloads))
+	}
+	if _, ok := payloads[0][STACKTRACE_KEY]; ok {
+		t.Errorf("unexpected stack trace for a Warn json log : %v", payloads[0])
+	}
+	trace, _ := payloads[1][STACKTRACE_KEY].(string)
+	if !strings.Contains(trace, "custom-slog-logger.TestStacktraceLevel") || strings.Contains(trace, "(*CustomHandler)") {
+		t.Errorf("unexpected stack trace in json log : %q", trace)
+	}
+
+	//the stack trace goes through ReplaceAttr, the redaction and MaxAttrs as the other attributes
+	replaceStacktrace := func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key == STACKTRACE_KEY {
+			a.Value = slog.StringValue("replaced")
+		}
+		return a
+	}
+	buf.Reset()
+	NewCustomLogger(buf, &CustomHandlerOptions{
+		Format:          FormatCompact,
+		StacktraceLevel: slog.LevelError,
+		RedactKeys:      []string{STACKTRACE_KEY},
+	}).Error("redacted")
+	if strings.Contains(buf.String(), "TestStacktraceLevel") {
+		t.Errorf("expected the stack trace to be redacted, got %q", buf.String())
+	}
+	buf.Reset()
+	NewCustomLogger(buf, &CustomHandlerOptions{
+		Format:          FormatCompact,
+		StacktraceLevel: slog.LevelError,
+		MaxAttrs:        1,
+		ReplaceAttr:     replaceStacktrace,
+	}).Error("limited", "first", 1)
+	if !strings.HasSuffix(buf.String(), " limited first=1 "+TRUNCATED_KEY+"=true\n") {
+		t.Errorf("expected the stack trace to count in MaxAttrs, got %q", buf.String())
+	}
+	buf.Reset()
+	NewCustomLogger(buf, &CustomHandlerOptions{
+		Format:          FormatCompact,
+		StacktraceLevel: slog.LevelError,
+		ReplaceAttr:     replaceStacktrace,
+	}).Error("replaced")
+	if !strings.HasSuffix(buf.String(), " replaced "+STACKTRACE_KEY+"=replaced\n") {
+		t.Errorf("expected the stack trace to go through ReplaceAttr, got %q", buf.String())
+	}
+}
+
+func TestNilContext(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, MinimumLevel: slog.LevelDebug}).
+		WithCtxAttrsKeys([]string{"request_id"})
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("logging with a nil context panicked : %v", r)
+		}
+	}()
+
+	var ctx context.Context
+	logger.InfoContext(ctx, "info")
+	logger.WarnContext(ctx, "warn")
+	logger.ErrorContextTextOnly(ctx, "error")
+	logger.DebugContext(ctx, "debug")
+	logger.Log(ctx, slog.LevelInfo, "log")
+	logger.LogAttrs(ctx, slog.LevelInfo, "log attrs")
+	if err := logger.Handler().Handle(ctx, goldenRecord(slog.LevelInfo, "handle")); err != nil {
+		t.Errorf("unexpected error handling a record with a nil context : %s", err)
+	}
+	if _, err := logger.Handler().FormatRecord(ctx, goldenRecord(slog.LevelInfo, "format")); err != nil {
+		t.Errorf("unexpected error formatting a record with a nil context : %s", err)
+	}
+
+	if count := strings.Count(buf.String(), "\n"); count != 7 {
+		t.Errorf("expected 7 logs, got %d :\n%s", count, buf.String())
+	}
+}
+
+// requestIDKey is a context key type like the ones of middlewares
+type requestIDKey struct{}
+
+func TestCtxExtractors(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{
+		Format: FormatCompact,
+		CtxExtractors: []CtxExtractor{
+			func(ctx context.Context) []slog.Attr {
+				if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+					return []slog.Attr{slog.String("request_id", id)}
+				}
+				return nil
+			},
+		},
+	}).WithCtxAttrsKeys([]string{"user"})
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "req-42")
+	ctx = context.WithValue(ctx, CtxKeyString("user"), "bob")
+	logger.InfoContext(ctx, "extracted")
+	logger.Info("without context values")
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 logs, got %d", len(lines))
+	}
+	if !strings.HasSuffix(lines[0], "extracted user=bob request_id=req-42") {
+		t.Errorf("expected the extracted attributes, got %q", lines[0])
+	}
+	if strings.Contains(lines[1], "request_id") {
+		t.Errorf("unexpected extracted attribute, got %q", lines[1])
+	}
+}
+
+func TestWithCtxAttr(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact}).
+		WithCtxAttr(requestIDKey{}, "request_id").
+		WithCtxAttr("x-tenant", "tenant")
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "req-42")
+	ctx = context.WithValue(ctx, CtxKeyString("x-tenant"), "acme")
+	logger.InfoContext(ctx, "renamed")
+	logger.Info("without context values")
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 logs, got %d", len(lines))
+	}
+	if !strings.HasSuffix(lines[0], "renamed request_id=req-42 tenant=acme") {
+		t.Errorf("expected the renamed context attributes, got %q", lines[0])
+	}
+	if strings.Contains(lines[1], "request_id") || strings.Contains(lines[1], "tenant") {
+		t.Errorf("unexpected context attribute, got %q", lines[1])
+	}
+}
+
+func TestNamed(t *testing.T) {
+	server, serverURL := customslogloggertest.NewCaptureServer()
+	defer server.Close()
+	buf := &bytes.Buffer{}
+	base := NewCustomLogger(buf, &CustomHandlerOptions{JsonLogURL: serverURL})
+
+	base.Named("http").Info("http log")
+	base.Named("http").Named("router").Info("router log")
+	base.Info("base log")
+
+	for _, expected := range []string{"============INFO [http]=============", "=========INFO [http.router]========="} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("text output doesn't contain %q :\n%s", expected, buf.String())
+		}
+	}
+
+	payloads := server.Payloads()
+	if len(payloads) != 3 {
+		t.Fatalf("expected 3 json payloads, got %d", len(payloads))
+	}
+	for i, expected := range []any{"http", "http.router", nil} {
+		if payloads[i][COMPONENT_KEY] != expected {
+			t.Errorf("expected component %v, got %v", expected, payloads[i][COMPONENT_KEY])
+		}
+	}
+
+	buf.Reset()
+	compact := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact}).Named("db")
+	compact.Info("compact log")
+	if !strings.HasPrefix(buf.String(), "INFO [db] ") {
+		t.Errorf("expected the component after the level, got %q", buf.String())
+	}
+}
+
+func TestJsonWriter(t *testing.T) {
+	ndjson := &bytes.Buffer{}
+	logger := NewCustomLogger(io.Discard, &CustomHandlerOptions{JsonWriter: ndjson})
+
+	logger.Info("first", "id", 1)
+	logger.WithGroup("req").Warn("second", "url", "/")
+	logger.ErrorTextOnly("text only")
+
+	lines := strings.Split(strings.TrimSuffix(ndjson.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 json lines, got %d :\n%s", len(lines), ndjson.String())
+	}
+	for i, expected := range []string{"first", "second"} {
+		payload := map[string]any{}
+		if err := json.Unmarshal([]byte(lines[i]), &payload); err != nil {
+			t.Fatalf("line %d is not valid json : %s", i, err)
+		}
+		if payload["msg"] != expected {
+			t.Errorf("expected message %q on line %d, got %v", expected, i, payload["msg"])
+		}
+	}
+}
+
+func TestTextAndJsonWriters(t *testing.T) {
+	text := &bytes.Buffer{}
+	ndjson := &bytes.Buffer{}
+	logger := NewCustomLogger(text, &CustomHandlerOptions{Format: FormatCompact, NoTime: true, JsonWriter: ndjson})
+
+	logger.Log(context.Background(), slog.LevelInfo, "both", "id", 1)
+	if text.String() != "INFO both id=1\n" {
+		t.Errorf("expected the text log, got %q", text.String())
+	}
+	payload := map[string]any{}
+	if err := json.Unmarshal(ndjson.Bytes(), &payload); err != nil || payload["msg"] != "both" {
+		t.Errorf("expected the json log, got %q", ndjson.String())
+	}
+
+	text.Reset()
+	ndjson.Reset()
+	logger.LogTextOnly(context.Background(), slog.LevelInfo, "text only")
+	logger.LogJsonOnly(context.Background(), slog.LevelInfo, "json only")
+	if strings.Contains(text.String(), "json only") || !strings.Contains(text.String(), "text only") {
+		t.Errorf("expected only the text only log on the text writer, got %q", text.String())
+	}
+	if strings.Contains(ndjson.String(), "text only") || !strings.Contains(ndjson.String(), "json only") {
+		t.Errorf("expected only the json only log on the json writer, got %q", ndjson.String())
+	}
+}
+
+// failingWriter is an io.Writer always failing, like a full disk
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, syscall.ENOSPC
+}
+
+func TestTextWriterError(t *testing.T) {
+	ndjson := &bytes.Buffer{}
+	var reported []error
+	logger := NewCustomLogger(failingWriter{}, &CustomHandlerOptions{
+		JsonWriter:           ndjson,
+		InternalErrorHandler: func(err error) { reported = append(reported, err) },
+	})
+
+	err := logger.Handler().Handle(context.Background(), goldenRecord(slog.LevelInfo, "full disk"))
+	if !errors.Is(err, syscall.ENOSPC) || !strings.Contains(err.Error(), "unable to write text log") {
+		t.Errorf("expected the writer error to be returned, got %v", err)
+	}
+	if !strings.Contains(ndjson.String(), "full disk") {
+		t.Errorf("expected the json log to be written despite the text writer error, got %q", ndjson.String())
+	}
+
+	//the logging methods of slog.Logger ignore the error, which is still reported
+	logger.LogAttrs(context.Background(), slog.LevelInfo, "full disk again")
+	if len(reported) != 1 || !errors.Is(reported[0], syscall.ENOSPC) {
+		t.Errorf("expected the writer error to be reported, got %v", reported)
+	}
+
+	err = NewCustomLogger(io.Discard, &CustomHandlerOptions{JsonWriter: failingWriter{}}).Handler().
+		Handle(context.Background(), goldenRecord(slog.LevelInfo, "json full disk"))
+	if !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("expected the json writer error to be returned, got %v", err)
+	}
+}
+
+func TestNewCustomLoggerE(t *testing.T) {
+	tests := []struct {
+		name    string
+		writer  io.Writer
+		options *CustomHandlerOptions
+		err     string
+	}{
+		{"valid", io.Discard, &CustomHandlerOptions{JsonLogURL: "https://logs.example.com/v1", JsonTimeout: time.Second}, ""},
+		{"nil options", io.Discard, nil, ""},
+		{"nil writer", nil, nil, "textWriter must not be nil"},
+		{"unparsable url", io.Discard, &CustomHandlerOptions{JsonLogURL: "http://[::1"}, "invalid JsonLogURL"},
+		{"bad scheme", io.Discard, &CustomHandlerOptions{JsonLogURL: "ftp://logs.example.com"}, "scheme must be http or https"},
+		{"relative url", io.Discard, &CustomHandlerOptions{JsonLogURL: "/logs"}, "scheme must be http or https"},
+		{"missing host", io.Discard, &CustomHandlerOptions{JsonLogURL: "http:///logs"}, "missing host"},
+		{"negative timeout", io.Discard, &CustomHandlerOptions{JsonTimeout: -time.Second}, "invalid JsonTimeout"},
+		{"bad color", io.Discard, &CustomHandlerOptions{ColorPalette: ColorPalette{Info: "blue"}}, "invalid ColorPalette"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logger, err := NewCustomLoggerE(test.writer, test.options)
+			if test.err == "" {
+				if err != nil || logger == nil {
+					t.Errorf("unexpected error : %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expected an error containing %q, got %v", test.err, err)
+			}
+			if logger != nil {
+				t.Errorf("expected no logger with invalid options")
+			}
+		})
+	}
+}
+
+func TestNestAttrsUnder(t *testing.T) {
+	jsonBuf := &bytes.Buffer{}
+	logger := NewCustomLogger(io.Discard, &CustomHandlerOptions{JsonWriter: jsonBuf, NestAttrsUnder: "attrs"})
+	logger.Info("real message", "msg", "attribute message", "level", 3, slog.Group("req", "id", 1))
+	logger.Info("without attributes")
+
+	lines := bytes.Split(bytes.TrimSuffix(jsonBuf.Bytes(), []byte("\n")), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 json logs, got %d", len(lines))
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(lines[0], &payload); err != nil {
+		t.Fatalf("invalid json log : %s", err)
+	}
+	if payload["msg"] != "real message" || payload["level"] != "INFO" {
+		t.Errorf("expected the envelope fields to be kept, got %v", payload)
+	}
+	expected := map[string]interface{}{"msg": "attribute message", "level": 3.0, "req": map[string]interface{}{"id": 1.0}}
+	if !reflect.DeepEqual(payload["attrs"], expected) {
+		t.Errorf("expected the attributes nested under attrs %v, got %v", expected, payload["attrs"])
+	}
+	if _, ok := payload["attr_msg"]; ok {
+		t.Errorf("unexpected renamed attribute : %v", payload)
+	}
+
+	payload = nil
+	if err := json.Unmarshal(lines[1], &payload); err != nil {
+		t.Fatalf("invalid json log : %s", err)
+	}
+	if _, ok := payload["attrs"]; ok {
+		t.Errorf("expected no attrs object without attributes, got %v", payload)
+	}
+}
+
+func TestJsonFieldNames(t *testing.T) {
+	server, serverURL := customslogloggertest.NewCaptureServer()
+	defer server.Close()
+	logger := NewCustomLogger(io.Discard, &CustomHandlerOptions{
+		JsonLogURL: serverURL,
+		AddSource:  true,
+		JsonFieldNames: JsonFieldNames{
+			Time:    "@timestamp",
+			Level:   "log.level",
+			Message: "message",
+			Source:  "log.origin",
+		},
+	})
+
+	logger.Warn("remapped", "message", "attribute message", "msg", "not reserved anymore")
+
+	payloads := server.Payloads()
+	if len(payloads) != 1 {
+		t.Fatalf("expected 1 json payload, got %d", len(payloads))
+	}
+	payload := payloads[0]
+	for key, expected := range map[string]any{
+		"log.level":    "WARN",
+		"message":      "remapped",
+		"attr_message": "attribute message",
+		"msg":          "not reserved anymore",
+	} {
+		if payload[key] != expected {
+			t.Errorf("expected %q for key %q, got %v", expected, key, payload[key])
+		}
+	}
+	if _, ok := payload["@timestamp"]; !ok {
+		t.Errorf("expected a @timestamp key : %v", payload)
+	}
+	if source, _ := payload["log.origin"].(map[string]interface{}); source == nil || filepath.Base(source["file"].(string)) != "logger_test.go" {
+		t.Errorf("expected the source in log.origin key : %v", payload)
+	}
+	for _, key := range []string{"time", "level", "source"} {
+		if _, ok := payload[key]; ok {
+			t.Errorf("unexpected default key %q : %v", key, payload)
+		}
+	}
+}
+
+func TestJsonMethodAndBearerToken(t *testing.T) {
+	received := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		received <- r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	t.Setenv("LOG_TOKEN", "s3cr3t")
+	options := &CustomHandlerOptions{JsonLogURL: server.URL, JsonMethod: "put"}
+	if err := options.SetBearerTokenFromEnv("LOG_TOKEN"); err != nil {
+		t.Fatalf("unable to set bearer token : %s", err)
+	}
+	NewCustomLogger(io.Discard, options).Info("put log")
+
+	select {
+	case authorization := <-received:
+		if authorization != "Bearer s3cr3t" {
+			t.Errorf("unexpected Authorization header %q", authorization)
+		}
+	default:
+		t.Fatalf("json log wasn't delivered with PUT")
+	}
+
+	if err := (&CustomHandlerOptions{}).SetBearerTokenFromEnv("UNSET_LOG_TOKEN"); err == nil {
+		t.Errorf("expected an error for an unset environment variable")
+	}
+
+	options = &CustomHandlerOptions{JsonLogURL: server.URL, JsonMethod: http.MethodGet}
+	if err := options.Validate(); err == nil || !strings.Contains(err.Error(), "invalid JsonMethod") {
+		t.Errorf("expected an invalid JsonMethod error, got %v", err)
+	}
+	r := goldenRecord(slog.LevelInfo, "get log")
+	if err := NewCustomLogger(io.Discard, options).Handler().Handle(context.Background(), r); err == nil {
+		t.Errorf("expected Handle to fail with an invalid JsonMethod")
+	}
+}
+
+func TestNewCustomHandler(t *testing.T) {
+	server, serverURL := customslogloggertest.NewCaptureServer()
+	defer server.Close()
+	buf := &bytes.Buffer{}
+	var handler slog.Handler = NewCustomHandler(buf, &CustomHandlerOptions{Format: FormatCompact, JsonLogURL: serverURL})
+
+	logger := slog.New(handler).With("id", 5).WithGroup("req")
+	logger.Info("std logger", "url", "/")
+	logger.Debug("filtered")
+
+	if !strings.Contains(buf.String(), "INFO") || !strings.HasSuffix(buf.String(), "std logger id=5 req.url=/\n") {
+		t.Errorf("unexpected text output %q", buf.String())
+	}
+	if payloads := server.Payloads(); len(payloads) != 1 || payloads[0]["msg"] != "std logger" {
+		t.Errorf("unexpected json payloads %v", payloads)
+	}
+}
+
+func TestWithError(t *testing.T) {
+	server, serverURL := customslogloggertest.NewCaptureServer()
+	defer server.Close()
+	buf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{JsonLogURL: serverURL, Format: FormatCompact})
+
+	if logger.WithError(nil) != logger {
+		t.Errorf("expected the same logger for a nil error")
+	}
+
+	err := fmt.Errorf("query failed: %w", errors.New("timeout"))
+	logger.WithError(err).Error("failed")
+
+	if !strings.HasSuffix(buf.String(), ` failed error="query failed: timeout"`+"\n") {
+		t.Errorf("unexpected text output %q", buf.String())
+	}
+	payloads := server.Payloads()
+	if len(payloads) != 1 {
+		t.Fatalf("expected 1 json payload, got %d", len(payloads))
+	}
+	if expected := []any{"query failed: timeout", "timeout"}; !reflect.DeepEqual(payloads[0][ERROR_KEY], expected) {
+		t.Errorf("unexpected error attribute %v, expected %v", payloads[0][ERROR_KEY], expected)
+	}
+}
+
+func TestWrapError(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact})
+
+	if err := logger.WrapError(nil, "unable to load config"); err != nil || buf.Len() != 0 {
+		t.Errorf("expected nothing logged nor returned for a nil error, got %v %q", err, buf.String())
+	}
+
+	cause := errors.New("file not found")
+	err := logger.WrapError(cause, "unable to load config", "path", "app.yaml")
+	if err == nil || err.Error() != "unable to load config: file not found" || !errors.Is(err, cause) {
+		t.Errorf("expected the wrapped error, got %v", err)
+	}
+	if !strings.Contains(buf.String(), "ERROR ") || !strings.HasSuffix(buf.String(), ` unable to load config path=app.yaml error="file not found"`+"\n") {
+		t.Errorf("unexpected text output %q", buf.String())
+	}
+}
+
+func TestFormatJSON(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	logger := NewCustomLogger(stdout, &CustomHandlerOptions{Format: FormatJSON, ColorizeLogs: true}).
+		Named("api").WithGroup("req")
+
+	logger.Warn("container log", "url", "/users", slog.Int("status", 200), slog.Bool("cached", true), slog.Group("user", slog.String("name", "bob")))
+
+	if strings.Count(stdout.String(), "\n") != 1 || !strings.HasSuffix(stdout.String(), "\n") {
+		t.Fatalf("expected a single json line, got %q", stdout.String())
+	}
+	payload := map[string]any{}
+	if err := json.Unmarshal(stdout.Bytes(), &payload); err != nil {
+		t.Fatalf("output is not valid json : %s", err)
+	}
+	expected := map[string]any{
+		"level":       "WARN",
+		"msg":         "container log",
+		COMPONENT_KEY: "api",
+		"req":         map[string]any{"url": "/users", "status": 200.0, "cached": true, "user": map[string]any{"name": "bob"}},
+	}
+	delete(payload, "time")
+	if !reflect.DeepEqual(payload, expected) {
+		t.Errorf("unexpected json log %v, expected %v", payload, expected)
+	}
+	//the numbers are json numbers, indexable by the log pipelines
+	if !strings.Contains(stdout.String(), `"status":200`) {
+		t.Errorf("expected the status as a json number, got %q", stdout.String())
+	}
+}
+
+func TestClose(t *testing.T) {
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		received.Add(1)
+	}))
+	defer server.Close()
+
+	logger := NewCustomLogger(io.Discard, &CustomHandlerOptions{JsonLogURL: server.URL, JsonTimeout: 10 * time.Millisecond})
+	logger.Info("first")
+	logger.Named("derived").Info("second")
+
+	if received.Load() == 2 {
+		t.Fatalf("expected the json logs to still be in flight after the timeout")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := logger.Close(ctx); err != nil {
+		t.Fatalf("unexpected error closing the logger : %s", err)
+	}
+	if count := received.Load(); count != 2 {
+		t.Errorf("expected 2 json logs received before Close returned, got %d", count)
+	}
+}
+
+func TestCloseDeadline(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	logger := NewCustomLogger(io.Discard, &CustomHandlerOptions{JsonLogURL: server.URL, JsonTimeout: 10 * time.Millisecond})
+	logger.Info("blocked")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := logger.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a deadline exceeded error, got %v", err)
+	}
+}
+
+func TestCloseConcurrentLogs(t *testing.T) {
+	server, serverURL := customslogloggertest.NewCaptureServer()
+	defer server.Close()
+
+	logger := NewCustomLogger(io.Discard, &CustomHandlerOptions{JsonLogURL: serverURL, InternalErrorHandler: func(error) {}})
+	var wg sync.WaitGroup
+	var started sync.Once
+	logging := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				logger.Info("concurrent", "j", j)
+				started.Do(func() { close(logging) })
+			}
+		}()
+	}
+	<-logging
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := logger.Close(ctx); err != nil {
+		t.Errorf("unexpected error closing the logger : %s", err)
+	}
+	wg.Wait()
+
+	//each json log is either delivered or refused because the handler is closed
+	if stats := logger.Stats(); stats.DeliveredCount == 0 || stats.DeliveredCount+stats.FailedCount != 160 {
+		t.Errorf("expected the 160 json logs to be delivered or refused, got %+v", stats)
+	}
+	if err := logger.Close(ctx); err != nil {
+		t.Errorf("unexpected error closing the logger again : %s", err)
+	}
+}
+
+func TestJsonDeliveryOutlivesContext(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	logger := NewCustomLogger(io.Discard, &CustomHandlerOptions{JsonLogURL: server.URL, JsonTimeout: 10 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	logger.InfoContext(ctx, "request done")
+	//the request handler returns, canceling its context while the json log is still being sent
+	cancel()
+	close(release)
+
+	if err := logger.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected error closing the logger : %s", err)
+	}
+	if stats := logger.Stats(); stats.DeliveredCount != 1 || stats.FailedCount != 0 {
+		t.Errorf("expected the json log to be delivered despite the canceled context, got %+v", stats)
+	}
+}
+
+// secretToken is a slog.LogValuer hiding its value
+type secretToken string
+
+func (s secretToken) LogValue() slog.Value {
+	return slog.StringValue("***")
+}
+
+// credentials is a slog.LogValuer logged as a group
+type credentials struct {
+	user  string
+	token secretToken
+}
+
+func (c credentials) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("user", c.user), slog.Any("token", c.token))
+}
+
+func TestLogValuer(t *testing.T) {
+	for _, format := range []TextFormat{FormatBanner, FormatCompact, FormatJSON} {
+		buf := &bytes.Buffer{}
+		jsonBuf := &bytes.Buffer{}
+		logger := NewCustomLogger(buf, &CustomHandlerOptions{Format: format, JsonWriter: jsonBuf})
+		logger.Info("login", "token", secretToken("s3cr3t"), "auth", credentials{user: "alice", token: "t0k3n"})
+
+		for _, output := range []string{buf.String(), jsonBuf.String()} {
+			if strings.Contains(output, "s3cr3t") || strings.Contains(output, "t0k3n") {
+				t.Errorf("expected the secret values to be hidden, got %q", output)
+			}
+			if !strings.Contains(output, "***") || !strings.Contains(output, "alice") {
+				t.Errorf("expected the resolved values, got %q", output)
+			}
+		}
+	}
+
+	var payload map[string]interface{}
+	jsonBuf := &bytes.Buffer{}
+	NewCustomLogger(io.Discard, &CustomHandlerOptions{JsonWriter: jsonBuf}).Info("login", "auth", credentials{user: "alice", token: "t0k3n"})
+	if err := json.Unmarshal(jsonBuf.Bytes(), &payload); err != nil {
+		t.Fatalf("invalid json log : %s", err)
+	}
+	if auth, ok := payload["auth"].(map[string]interface{}); !ok || auth["token"] != "***" {
+		t.Errorf("expected the LogValuer group to be nested, got %v", payload["auth"])
+	}
+}
+
+func TestStats(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	logger := NewCustomLogger(io.Discard, &CustomHandlerOptions{JsonLogURL: failing.URL, MinimumLevel: slog.LevelInfo})
+	logger.Info("failed")
+	logger.Named("derived").Debug("dropped")
+	logger.Debug("dropped")
+
+	stats := logger.Stats()
+	if stats.FailedCount != 1 || stats.DeliveredCount != 0 {
+		t.Errorf("expected 1 failed json log, got %+v", stats)
+	}
+	if stats.DroppedByLevelCount != 2 {
+		t.Errorf("expected 2 records dropped by level, got %+v", stats)
+	}
+
+	server, serverURL := customslogloggertest.NewCaptureServer()
+	defer server.Close()
+	logger = NewCustomLogger(io.Discard, &CustomHandlerOptions{JsonLogURL: serverURL})
+	logger.Info("delivered")
+	if stats := logger.Stats(); stats.DeliveredCount != 1 || stats.FailedCount != 0 {
+		t.Errorf("expected 1 delivered json log, got %+v", stats)
+	}
+}
+
+func TestStatsTimedOut(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	logger := NewCustomLogger(io.Discard, &CustomHandlerOptions{JsonLogURL: server.URL, JsonTimeout: 10 * time.Millisecond})
+	logger.Info("slow")
+	if stats := logger.Stats(); stats.TimedOutCount != 1 {
+		t.Errorf("expected 1 timed out json log, got %+v", stats)
+	}
+
+	if err := logger.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected error closing the logger : %s", err)
+	}
+	if stats := logger.Stats(); stats.DeliveredCount != 1 {
+		t.Errorf("expected the timed out json log to be delivered in background, got %+v", stats)
+	}
+}
+
+func TestJsonDryRun(t *testing.T) {
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+	}))
+	defer server.Close()
+
+	dryRun := &bytes.Buffer{}
+	logger := NewCustomLogger(io.Discard, &CustomHandlerOptions{JsonLogURL: server.URL, JsonDryRun: true, JsonDryRunWriter: dryRun})
+	logger.Info("dry run", "count", 3)
+	if err := logger.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected error closing the logger : %s", err)
+	}
+
+	if received.Load() != 0 {
+		t.Errorf("expected no request in dry run, got %d", received.Load())
+	}
+	line, found := strings.CutPrefix(dryRun.String(), "POST "+server.URL+" ")
+	if !found || !strings.HasSuffix(line, "\n") {
+		t.Fatalf("expected the method, url and payload written on the dry run writer, got %q", dryRun.String())
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &payload); err != nil {
+		t.Fatalf("invalid json log : %s", err)
+	}
+	if payload["msg"] != "dry run" || payload["count"] != 3.0 {
+		t.Errorf("unexpected dry run payload %v", payload)
+	}
+}
+
+func TestSyncJson(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	var reported []error
+	logger := NewCustomLogger(io.Discard, &CustomHandlerOptions{
+		JsonLogURL:           failing.URL,
+		SyncJson:             true,
+		InternalErrorHandler: func(err error) { reported = append(reported, err) },
+	})
+	record := slog.NewRecord(time.Now(), slog.LevelInfo, "failed", 0)
+	err := logger.Handler().Handle(context.Background(), record)
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("expected Handle to return the delivery error, got %v", err)
+	}
+	if stats := logger.Stats(); stats.FailedCount != 1 {
+		t.Errorf("expected 1 failed json log, got %+v", stats)
+	}
+
+	//the logging methods of slog.Logger ignore the error, which is still reported
+	logger.LogAttrs(context.Background(), slog.LevelInfo, "failed again")
+	if len(reported) != 1 {
+		t.Errorf("expected the delivery error to be reported, got %v", reported)
+	}
+
+	var received atomic.Int32
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		received.Add(1)
+	}))
+	defer slow.Close()
+
+	logger = NewCustomLogger(io.Discard, &CustomHandlerOptions{JsonLogURL: slow.URL, SyncJson: true, JsonTimeout: 5 * time.Second})
+	if err := logger.Handler().Handle(context.Background(), record); err != nil {
+		t.Errorf("unexpected error sending the json log : %s", err)
+	}
+	if received.Load() != 1 {
+		t.Errorf("expected the json log to be delivered when Handle returns")
+	}
+}
+
+func TestDedupeAttrs(t *testing.T) {
+	buf := &bytes.Buffer{}
+	jsonBuf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, JsonWriter: jsonBuf}).
+		With("url", "/first", "user", "bob").WithCtxAttrsKeys([]string{"user"})
+	ctx := context.WithValue(context.Background(), CtxKeyString("user"), "alice")
+	logger.InfoContext(ctx, "request", "url", "/last")
+
+	output := buf.String()
+	if strings.Count(output, "url=") != 1 || !strings.Contains(output, "url=/last") {
+		t.Errorf("expected only the last url, got %q", output)
+	}
+	if strings.Count(output, "user=") != 1 || !strings.Contains(output, "user=alice") {
+		t.Errorf("expected only the context user, got %q", output)
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(jsonBuf.Bytes(), &payload); err != nil {
+		t.Fatalf("invalid json log : %s", err)
+	}
+	if payload["url"] != "/last" || payload["user"] != "alice" {
+		t.Errorf("expected the last values in the json log, got %v", payload)
+	}
+
+	buf.Reset()
+	NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact}).
+		With("url", "/root").WithGroup("req").With("url", "/first").Info("grouped", "url", "/last")
+	output = buf.String()
+	if !strings.Contains(output, " url=/root") || !strings.Contains(output, "req.url=/last") || strings.Contains(output, "/first") {
+		t.Errorf("expected the keys to be deduplicated within their group, got %q", output)
+	}
+}
+
+func TestDeriveForeignHandler(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := &CustomLogger{slog.New(slog.NewTextHandler(buf, nil))}
+
+	for name, derive := range map[string]func() *CustomLogger{
+		"WithCtxAttrsKeys": func() *CustomLogger { return logger.WithCtxAttrsKeys([]string{"request_id"}) },
+		"WithSampling":     func() *CustomLogger { return logger.WithSampling(1, 10) },
+		"WithCtxAttr":      func() *CustomLogger { return logger.WithCtxAttr("x-request-id", "request_id") },
+		"WithWriter":       func() *CustomLogger { return logger.WithWriter(io.Discard) },
+		"Named":            func() *CustomLogger { return logger.Named("db") },
+	} {
+		derived := derive()
+		if derived.Logger != logger.Logger {
+			t.Errorf("expected %s to keep the handler of a foreign logger", name)
+		}
+	}
+	logger.Info("foreign")
+	if !strings.Contains(buf.String(), "msg=foreign") {
+		t.Errorf("expected the foreign logger to keep logging, got %q", buf.String())
+	}
+	if stats := logger.Stats(); stats != (HandlerStats{}) {
+		t.Errorf("expected zero statistics for a foreign logger, got %+v", stats)
+	}
+}
+
+func TestCloneLogger(t *testing.T) {
+	textBuf := &bytes.Buffer{}
+	jsonBuf := &bytes.Buffer{}
+	logger := NewCustomLogger(textBuf, &CustomHandlerOptions{Format: FormatCompact, JsonWriter: jsonBuf}).With("service", "api")
+	textClone, jsonClone := logger.Clone(), logger.Clone()
+	if textClone.Handler() == logger.Handler() || textClone.Handler().Mutex == logger.Handler().Mutex {
+		t.Fatalf("expected a new handler with its own mutex")
+	}
+
+	//the text only and json only logs of the clones don't change the routing of each other
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			textClone.InfoTextOnly("text only")
+		}()
+		go func() {
+			defer wg.Done()
+			jsonClone.InfoJsonOnly("json only")
+		}()
+	}
+	wg.Wait()
+
+	if count := strings.Count(textBuf.String(), "text only service=api\n"); count != 50 || strings.Contains(textBuf.String(), "json only") {
+		t.Errorf("expected the 50 text only logs on the text writer, got %d :\n%s", count, textBuf.String())
+	}
+	if count := strings.Count(jsonBuf.String(), `"msg":"json only"`); count != 50 || strings.Contains(jsonBuf.String(), "text only") {
+		t.Errorf("expected the 50 json only logs on the json writer, got %d :\n%s", count, jsonBuf.String())
+	}
+	if stats := logger.Stats(); stats != textClone.Stats() {
+		t.Errorf("expected the clones to share the statistics of the logger, got %+v and %+v", stats, textClone.Stats())
+	}
+}
+
+func TestSetAsDefault(t *testing.T) {
+	previous := slog.Default()
+	defer slog.SetDefault(previous)
+
+	buf := &bytes.Buffer{}
+	jsonBuf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, AddSource: true, NoTime: true, JsonWriter: jsonBuf})
+	logger.SetAsDefault()
+
+	//the routing of a TextOnly call doesn't leak into the default logger
+	logger.InfoTextOnly("text only")
+	buf.Reset()
+
+	logger.Info("same", "count", 1)
+	direct := buf.String()
+	buf.Reset()
+	slog.Info("same", "count", 1)
+	viaDefault := buf.String()
+
+	lineNumber := regexp.MustCompile(`:\d+ `)
+	if lineNumber.ReplaceAllString(viaDefault, ":N ") != lineNumber.ReplaceAllString(direct, ":N ") {
+		t.Errorf("expected slog.Info() to log as a direct call :\n%s\ngot :\n%s", direct, viaDefault)
+	}
+	if !strings.Contains(viaDefault, "logger_test.go:") {
+		t.Errorf("expected the source of slog.Info() to be its caller, got %q", viaDefault)
+	}
+	if strings.Count(jsonBuf.String(), `"same"`) != 2 {
+		t.Errorf("expected the json logs of both calls, got %q", jsonBuf.String())
+	}
+}
+
+func TestJsonValidate(t *testing.T) {
+	server, serverURL := customslogloggertest.NewCaptureServer()
+	defer server.Close()
+	var reported []error
+	logger := NewCustomLogger(io.Discard, &CustomHandlerOptions{
+		JsonLogURL: serverURL,
+		JsonValidate: func(data map[string]interface{}) error {
+			if status, ok := data["status"]; ok {
+				if _, ok := status.(int64); !ok {
+					return fmt.Errorf("status must be a number, got %T", status)
+				}
+			}
+			return nil
+		},
+		InternalErrorHandler: func(err error) { reported = append(reported, err) },
+	})
+
+	err := logger.Handler().Handle(context.Background(), goldenRecord(slog.LevelInfo, "stringified", slog.String("status", "200")))
+	if err == nil || !strings.Contains(err.Error(), "status must be a number, got string") {
+		t.Errorf("expected the stringified status to be rejected, got %v", err)
+	}
+	if len(reported) != 1 {
+		t.Errorf("expected the validation error to be reported, got %v", reported)
+	}
+
+	if err := logger.Handler().Handle(context.Background(), goldenRecord(slog.LevelInfo, "no status")); err != nil {
+		t.Errorf("unexpected validation error : %s", err)
+	}
+	if bodies := server.Bodies(); len(bodies) != 1 || !strings.Contains(string(bodies[0]), "no status") {
+		t.Errorf("expected only the valid json log to be sent, got %q", bodies)
+	}
+}
+
+func TestJsonAllowKeys(t *testing.T) {
+	buf := &bytes.Buffer{}
+	jsonBuf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{
+		Format:        FormatCompact,
+		JsonWriter:    jsonBuf,
+		JsonAllowKeys: []string{"status", "request.method", "user", "req.headers.host"},
+	}).Named("api")
+	logger.WithGroup("request").Info("handled", "method", "GET", "email", "bob@example.com")
+	logger.Info("logged in", "status", 200, "ssn", "123-45-6789", slog.Group("user", "id", 7, "name", "bob"))
+	logger.Info("grouped", RequestAttrs(httptest.NewRequest("POST", "/login", nil)),
+		slog.Group("req", slog.Group("headers", "host", "example.com", "cookie", "secret")), slog.Group("session", "id", 3))
+
+	lines := strings.Split(strings.TrimSpace(jsonBuf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 2 json logs, got %q", jsonBuf.String())
+	}
+	first, second := map[string]interface{}{}, map[string]interface{}{}
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("invalid json log : %s", err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatalf("invalid json log : %s", err)
+	}
+
+	if request, _ := first["request"].(map[string]interface{}); fmt.Sprint(request["method"]) != "GET" || request["email"] != nil {
+		t.Errorf("expected only the allowed request.method attribute, got %v", first)
+	}
+	if fmt.Sprint(second["status"]) != "200" || second["ssn"] != nil {
+		t.Errorf("expected only the allowed status attribute, got %v", second)
+	}
+	if user, _ := second["user"].(map[string]interface{}); fmt.Sprint(user["id"]) != "7" || fmt.Sprint(user["name"]) != "bob" {
+		t.Errorf("expected the allowed user group with all its members, got %v", second)
+	}
+
+	//the members of the record groups are allowed by their dotted path
+	third := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(lines[2]), &third); err != nil {
+		t.Fatalf("invalid json log : %s", err)
+	}
+	delete(third, "time")
+	expected := map[string]interface{}{
+		"level": "INFO", "msg": "grouped", "component": "api",
+		REQUEST_KEY: map[string]interface{}{"method": "POST"},
+		"req":       map[string]interface{}{"headers": map[string]interface{}{"host": "example.com"}},
+	}
+	if !reflect.DeepEqual(third, expected) {
+		t.Errorf("expected only the allowed group members %v, got %v", expected, third)
+	}
+
+	for _, key := range []string{"time", "level", "msg", "component"} {
+		if second[key] == nil {
+			t.Errorf("expected the envelope field %s to be kept, got %v", key, second)
+		}
+	}
+
+	for _, expected := range []string{"request.email=bob@example.com", "ssn=123-45-6789"} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected the text log to keep %s, got %q", expected, buf.String())
+		}
+	}
+}
+
+func TestWithWriter(t *testing.T) {
+	base := &bytes.Buffer{}
+	file := &bytes.Buffer{}
+	logger := NewCustomLogger(base, &CustomHandlerOptions{Format: FormatCompact}).
+		WithGroup("db").With("table", "users").WithCtxAttrsKeys([]string{"request_id"}).Named("store")
+	derived := logger.WithWriter(file)
+
+	ctx := context.WithValue(context.Background(), CtxKeyString("request_id"), "abc")
+	derived.InfoContext(ctx, "derived")
+	logger.InfoContext(ctx, "original")
+
+	if output := file.String(); !strings.Contains(output, "[store] ") || !strings.Contains(output, "derived db.table=users db.request_id=abc") {
+		t.Errorf("expected the derived log with the same state on the new writer, got %q", output)
+	}
+	if strings.Contains(file.String(), "original") {
+		t.Errorf("expected the original logger to keep its writer, got %q", file.String())
+	}
+	if output := base.String(); !strings.Contains(output, "original db.table=users") || strings.Contains(output, "derived") {
+		t.Errorf("expected only the original log on the old writer, got %q", output)
+	}
+}
+
+func TestAddSequence(t *testing.T) {
+	logger, memory := NewCaptureLogger(&CustomHandlerOptions{AddSequence: true})
+
+	const goroutines, logs = 8, 50
+	wg := sync.WaitGroup{}
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(l *CustomLogger) {
+			defer wg.Done()
+			for j := 0; j < logs; j++ {
+				l.Info("concurrent")
+			}
+		}(logger.Named(fmt.Sprintf("worker%d", i)))
+	}
+	wg.Wait()
+
+	records := memory.Records()
+	if len(records) != goroutines*logs {
+		t.Fatalf("expected %d records, got %d", goroutines*logs, len(records))
+	}
+	seen := make(map[uint64]bool)
+	for _, record := range records {
+		v, ok := record.Attr(SEQUENCE_KEY)
+		if !ok {
+			t.Fatalf("expected a sequence number in %+v", record)
+		}
+		seq := v.Uint64()
+		if seq == 0 || seq > goroutines*logs || seen[seq] {
+			t.Errorf("unexpected or duplicated sequence number %d", seq)
+		}
+		seen[seq] = true
+	}
+
+	buf := &bytes.Buffer{}
+	jsonBuf := &bytes.Buffer{}
+	logger = NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, AddSequence: true, JsonWriter: jsonBuf})
+	logger.Info("first")
+	logger.Info("second")
+	if !strings.Contains(buf.String(), "first seq=1\n") || !strings.Contains(buf.String(), "second seq=2\n") {
+		t.Errorf("expected the sequence numbers in the text logs, got %q", buf.String())
+	}
+	//the sequence numbers are json numbers, so that the backends sort them numerically
+	decoder := json.NewDecoder(jsonBuf)
+	for expected := 1.0; expected <= 2; expected++ {
+		var payload map[string]interface{}
+		if err := decoder.Decode(&payload); err != nil {
+			t.Fatalf("invalid json log : %s", err)
+		}
+		if seq, ok := payload[SEQUENCE_KEY].(float64); !ok || seq != expected {
+			t.Errorf("expected the sequence number %v as a json number, got %#v", expected, payload[SEQUENCE_KEY])
+		}
+	}
+
+	//the sequence number is an attribute like the others : sorted, counted in MaxAttrs and rewritten by ReplaceAttr
+	buf.Reset()
+	logger = NewCustomLogger(buf, &CustomHandlerOptions{
+		Format:      FormatCompact,
+		AddSequence: true,
+		SortAttrs:   true,
+		MaxAttrs:    2,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == SEQUENCE_KEY {
+				a.Key = "order"
+			}
+			return a
+		},
+	})
+	logger.Info("limited", "b", 1, "a", 2)
+	if expected := "limited a=2 b=1 " + TRUNCATED_KEY + "=true\n"; !strings.HasSuffix(buf.String(), expected) {
+		t.Errorf("expected the sequence number to be counted in MaxAttrs, got %q", buf.String())
+	}
+	formatted, err := logger.Handler().FormatRecord(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "formatted", 0))
+	if err != nil || !strings.HasSuffix(formatted, "formatted order=2\n") {
+		t.Errorf("expected the renamed sequence number in the formatted record, got %q (%v)", formatted, err)
+	}
+}
+
+func TestOnRecord(t *testing.T) {
+	buf := &bytes.Buffer{}
+	var received []slog.Record
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, RedactKeys: []string{"password"}, OnRecord: func(ctx context.Context, r slog.Record) {
+		if buf.Len() == 0 {
+			t.Errorf("expected the hook to be called after the record is logged")
+		}
+		received = append(received, r)
+		if r.Level >= slog.LevelError {
+			panic("alert failure")
+		}
+	}})
+
+	logger.WithGroup("req").Info("ignored", "id", 5, "password", "hunter2")
+	logger.Error("page me")
+	logger.Info("after panic")
+
+	if len(received) != 3 {
+		t.Fatalf("expected the hook to receive 3 records, got %d", len(received))
+	}
+	if received[1].Level != slog.LevelError || received[1].Message != "page me" {
+		t.Errorf("unexpected record received by the hook : %+v", received[1])
+	}
+	//the hook receives the prepared record, nested in its groups and redacted
+	members := map[string]slog.Value{}
+	received[0].Attrs(func(a slog.Attr) bool {
+		if a.Key == "req" {
+			for _, member := range a.Value.Group() {
+				members[member.Key] = member.Value
+			}
+		}
+		return true
+	})
+	if members["id"].String() != "5" || members["password"].String() != REDACTED_VALUE {
+		t.Errorf("expected the hook to receive the prepared record attributes, got %v", members)
+	}
+	if !strings.Contains(buf.String(), "after panic") {
+		t.Errorf("expected the logger to keep logging after a panic in the hook, got %q", buf.String())
+	}
+}
+
+func TestOnRecordReentrant(t *testing.T) {
+	buf := &bytes.Buffer{}
+	var logger *CustomLogger
+	logger = NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, OnRecord: func(ctx context.Context, r slog.Record) {
+		//alerting through the same logger
+		if r.Level >= slog.LevelError {
+			logger.Warn("alert sent", "about", r.Message)
+		}
+	}})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		logger.Error("page me")
+		logger.LogAttrs(context.Background(), slog.LevelError, "page me again")
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("logging from the OnRecord hook deadlocked")
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 4 || !strings.HasSuffix(lines[1], "alert sent about=\"page me\"") || !strings.HasSuffix(lines[3], "alert sent about=\"page me again\"") {
+		t.Errorf("expected each alert logged after its record, got %q", buf.String())
+	}
+}
+
+func TestMaxAttrs(t *testing.T) {
+	buf := &bytes.Buffer{}
+	jsonBuf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, MaxAttrs: 2, JsonWriter: jsonBuf})
+	logger.With("a", 1).Info("many", "b", 2, "c", 3, "d", 4)
+
+	if !strings.HasSuffix(buf.String(), " many a=1 b=2 truncated=true\n") {
+		t.Errorf("expected the attributes to be truncated, got %q", buf.String())
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(jsonBuf.Bytes(), &payload); err != nil {
+		t.Fatalf("invalid json log : %s", err)
+	}
+	if _, ok := payload["c"]; ok || payload["b"] == nil || payload[TRUNCATED_KEY] == nil {
+		t.Errorf("expected the json attributes to be truncated, got %v", payload)
+	}
+
+	buf.Reset()
+	logger.Info("few", "b", 2)
+	if strings.Contains(buf.String(), "truncated") {
+		t.Errorf("expected no truncation under the limit, got %q", buf.String())
+	}
+}
+
+func TestMaxJsonBytes(t *testing.T) {
+	server, serverURL := customslogloggertest.NewCaptureServer()
+	defer server.Close()
+	logger := NewCustomLogger(io.Discard, &CustomHandlerOptions{JsonLogURL: serverURL, MaxJsonBytes: 200})
+
+	logger.Info("small", "id", 5)
+	logger.Info("huge", "payload", strings.Repeat("x", 1000))
+	logger.Info(strings.Repeat("huge message ", 50))
+
+	payloads := server.Payloads()
+	if len(payloads) != 2 {
+		t.Fatalf("expected 2 json logs sent, got %d", len(payloads))
+	}
+	if payloads[0]["id"] == nil || payloads[0][TRUNCATED_KEY] != nil {
+		t.Errorf("expected the small json log untouched, got %v", payloads[0])
+	}
+	if payloads[1]["payload"] != nil || fmt.Sprint(payloads[1][TRUNCATED_KEY]) != "true" || payloads[1]["msg"] != "huge" {
+		t.Errorf("expected the huge json log without its attributes, got %v", payloads[1])
+	}
+	if stats := logger.Stats(); stats.DroppedBySizeCount != 1 {
+		t.Errorf("expected 1 json log dropped by size, got %+v", stats)
+	}
+}
+
+func TestBannerWidth(t *testing.T) {
+	levels := []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError, slog.LevelError + 2, LevelPanic}
+	for _, width := range []int{0, 50} {
+		expected := width
+		if expected == 0 {
+			expected = DEFAULT_BANNER_WIDTH
+		}
+		for _, level := range levels {
+			buf := &bytes.Buffer{}
+			handler := NewCustomLogger(buf, &CustomHandlerOptions{BannerWidth: width, MinimumLevel: slog.LevelDebug}).Handler()
+			handler.Handle(context.Background(), goldenRecord(level, "aligned"))
+
+			lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+			header, footer := lines[0], lines[len(lines)-1]
+			if len(header) != expected || !strings.Contains(header, levelName(level)) {
+				t.Errorf("expected a %d wide header for %s, got %q", expected, level, header)
+			}
+			if footer != strings.Repeat("=", expected) {
+				t.Errorf("expected a %d wide footer, got %q", expected, footer)
+			}
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	NewCustomLogger(buf, &CustomHandlerOptions{BannerWidth: 10}).Named("a.very.long.component").Info("overflow")
+	if header := strings.SplitN(buf.String(), "\n", 2)[0]; header != "=INFO [a.very.long.component]=" {
+		t.Errorf("expected a long label to be surrounded by one separator, got %q", header)
+	}
+}
+
+func TestMultiLineMessage(t *testing.T) {
+	msg := "query failed :\nSELECT *\n  FROM users"
+
+	buf := &bytes.Buffer{}
+	handler := NewCustomLogger(buf, &CustomHandlerOptions{}).Handler()
+	handler.Handle(context.Background(), goldenRecord(slog.LevelInfo, msg, slog.Int("id", 5)))
+	lines := strings.Split(buf.String(), "\n")
+	if lines[1] != " query failed :" || lines[2] != " SELECT *" || lines[3] != "   FROM users " || lines[4] != " 2024-05-17 14:30:00  " {
+		t.Errorf("expected the continuation lines of the message indented, got %q", lines)
+	}
+
+	buf.Reset()
+	handler = NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact}).Handler()
+	handler.Handle(context.Background(), goldenRecord(slog.LevelInfo, msg, slog.Int("id", 5)))
+	if output := buf.String(); output != "INFO 2024-05-17 14:30:00 query failed :\n\tSELECT *\n\t  FROM users id=5\n" {
+		t.Errorf("expected the continuation lines of the compact message indented, got %q", output)
+	}
+
+	jsonBuf := &bytes.Buffer{}
+	NewCustomLogger(io.Discard, &CustomHandlerOptions{JsonWriter: jsonBuf}).Info(msg)
+	if strings.Count(jsonBuf.String(), "\n") != 1 || !strings.Contains(jsonBuf.String(), `query failed :\nSELECT *\n  FROM users`) {
+		t.Errorf("expected the newlines of the message escaped in the json line, got %q", jsonBuf.String())
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(jsonBuf.Bytes(), &payload); err != nil || payload["msg"] != msg {
+		t.Errorf("expected the message to round-trip through json, got %v (%v)", payload["msg"], err)
+	}
+}
+
+func TestInternalErrorHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	var reported atomic.Int32
+	var lastErr atomic.Value
+	logger := NewCustomLogger(io.Discard, &CustomHandlerOptions{
+		JsonLogURL:            server.URL,
+		InternalErrorInterval: time.Second,
+		InternalErrorHandler: func(err error) {
+			reported.Add(1)
+			lastErr.Store(err)
+		},
+	})
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(l *CustomLogger) {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				l.Info("unavailable service")
+			}
+		}(logger.Named(fmt.Sprintf("worker%d", i)))
+	}
+	wg.Wait()
+	logger.Close(context.Background())
+
+	if stats := logger.Stats(); stats.FailedCount != 100 {
+		t.Errorf("expected 100 failed json logs, got %+v", stats)
+	}
+	if count := reported.Load(); count != 1 {
+		t.Errorf("expected 1 reported error under a 1-per-second cap, got %d", count)
+	}
+	if err, _ := lastErr.Load().(error); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("expected the status error to be reported, got %v", err)
+	}
+}
+
+func TestWithGroupAttrs(t *testing.T) {
+	server, serverURL := customslogloggertest.NewCaptureServer()
+	defer server.Close()
+	logger := NewCustomLogger(io.Discard, &CustomHandlerOptions{JsonLogURL: serverURL}).
+		WithGroup("app").WithGroupAttrs("db", "host", "localhost", slog.Int("port", 5432)).With("user", "bob")
+	logger.Info("connected", "latency", "3ms")
+
+	payloads := server.Payloads()
+	if len(payloads) != 1 {
+		t.Fatalf("expected 1 json payload, got %d", len(payloads))
+	}
+	app, _ := payloads[0]["app"].(map[string]interface{})
+	db, _ := app["db"].(map[string]interface{})
+	if db == nil || db["host"] != "localhost" || fmt.Sprint(db["port"]) != "5432" || len(db) != 2 {
+		t.Errorf("expected the attributes nested under app.db, got %v", payloads[0])
+	}
+	if app["user"] != "bob" || app["latency"] != "3ms" {
+		t.Errorf("expected the other attributes not to be grouped under db, got %v", payloads[0])
+	}
+}
+
+func TestPanic(t *testing.T) {
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		received.Add(1)
+	}))
+	defer server.Close()
+
+	buf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, NoTime: true, JsonLogURL: server.URL, JsonTimeout: time.Second})
+	func() {
+		defer func() {
+			value := recover()
+			if value != "unrecoverable state id=7 reason=\"disk full\"" {
+				t.Errorf("expected a panic with the rendered message, got %v", value)
+			}
+			if !strings.HasPrefix(buf.String(), "PANIC unrecoverable state id=7") {
+				t.Errorf("expected the log to be written before the panic, got %q", buf.String())
+			}
+			if received.Load() != 1 {
+				t.Errorf("expected the json log to be delivered before the panic")
+			}
+		}()
+		logger.Panic("unrecoverable state", "id", 7, "reason", "disk full")
+		t.Errorf("expected Panic() to panic")
+	}()
+
+	buf.Reset()
+	colorized := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, ColorizeLogs: true})
+	func() {
+		defer func() { recover() }()
+		colorized.Panic("colorized")
+	}()
+	if !strings.HasPrefix(buf.String(), COLOR_MAGENTA+"PANIC") {
+		t.Errorf("expected the panic level in magenta, got %q", buf.String())
+	}
+	if level, err := ParseLevel("panic"); err != nil || level != LevelPanic {
+		t.Errorf("expected the panic level to be parsed, got %v %v", level, err)
+	}
+}
+
+func TestLoggerEnabled(t *testing.T) {
+	logger := NewCustomLogger(io.Discard, &CustomHandlerOptions{MinimumLevel: slog.LevelInfo})
+	ctx := context.Background()
+
+	if logger.Enabled(ctx, slog.LevelDebug) {
+		t.Errorf("expected the Debug level below the minimum level to be disabled")
+	}
+	if !logger.Enabled(ctx, slog.LevelInfo) || !logger.Named("derived").Enabled(ctx, slog.LevelError) {
+		t.Errorf("expected the levels from the minimum level to be enabled")
+	}
+	if stats := logger.Stats(); stats.DroppedByLevelCount != 0 {
+		t.Errorf("expected a check not to count as a dropped record, got %+v", stats)
+	}
+
+	multi := &CustomLogger{slog.New(NewMultiHandler(NewCustomHandler(io.Discard, &CustomHandlerOptions{MinimumLevel: slog.LevelWarn})))}
+	if multi.Enabled(ctx, slog.LevelInfo) || !multi.Enabled(ctx, slog.LevelWarn) {
+		t.Errorf("expected Enabled to delegate to any handler")
+	}
+}
+
+func TestAttrOrder(t *testing.T) {
+	ctx := context.WithValue(context.Background(), CtxKeyString("request_id"), "abc")
+	for order, expected := range map[AttrOrder]string{
+		AttrOrderAdditionalFirst: " ordered service=api id=5 url=/users status=200 request_id=abc\n",
+		AttrOrderRecordFirst:     " ordered id=5 url=/users status=200 service=api request_id=abc\n",
+	} {
+		buf := &bytes.Buffer{}
+		logger := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, AttrOrder: order}).
+			With("url", "/home", "service", "api").WithCtxAttrsKeys([]string{"request_id"})
+		logger.InfoContext(ctx, "ordered", "id", 5, "url", "/users", "status", 200)
+
+		if !strings.HasSuffix(buf.String(), expected) {
+			t.Errorf("expected the attributes in the order %q, got %q", expected, buf.String())
+		}
+	}
+}
+
+func TestSourceHyperlink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	options := &CustomHandlerOptions{Format: FormatCompact, AddSource: true, ColorizeLogs: true, SourceHyperlink: true, SourceURLTemplate: "vscode://file/{path}:{line}"}
+	_, file, line, _ := runtime.Caller(0)
+	NewCustomLogger(buf, options).Info("linked")
+
+	link := fmt.Sprintf("\033]8;;vscode://file/%s:%d\033\\@logger_test.go:%d\033]8;;\033\\", filepath.ToSlash(file), line+1, line+1)
+	if !strings.Contains(buf.String(), link) {
+		t.Errorf("expected the source hyperlink %q, got %q", link, buf.String())
+	}
+
+	for _, disabled := range []*CustomHandlerOptions{
+		{Format: FormatCompact, AddSource: true, ColorizeLogs: true},
+		{Format: FormatCompact, AddSource: true, SourceHyperlink: true},
+	} {
+		buf.Reset()
+		NewCustomLogger(buf, disabled).Info("plain")
+		if strings.Contains(buf.String(), "\033]8;;") || !strings.Contains(buf.String(), "@logger_test.go:") {
+			t.Errorf("expected the plain source, got %q", buf.String())
+		}
+	}
+}
+
+func TestSourceFunction(t *testing.T) {
+	buf := &bytes.Buffer{}
+	jsonBuf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, AddSource: true, SourceFunction: true, JsonWriter: jsonBuf})
+	_, _, line, _ := runtime.Caller(0)
+	logger.Info("with function")
+
+	expected := fmt.Sprintf("@custom-slog-logger.TestSourceFunction (logger_test.go:%d) with function", line+1)
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected the source %q, got %q", expected, buf.String())
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(jsonBuf.Bytes(), &payload); err != nil {
+		t.Fatalf("invalid json log : %s", err)
+	}
+	source, _ := payload["source"].(map[string]interface{})
+	if function, _ := source["function"].(string); !strings.HasSuffix(function, ".TestSourceFunction") {
+		t.Errorf("expected the caller function in the json source, got %v", payload["source"])
+	}
+	if file, _ := source["file"].(string); filepath.Base(file) != "logger_test.go" || source["line"] != float64(line+1) {
+		t.Errorf("expected the caller file and line in the json source, got %v", payload["source"])
+	}
+}
+
+func TestNoSource(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, AddSource: true, MinimumLevel: slog.LevelDebug})
+	logger.Debug("with source")
+	logger.Debug("suppressed", "id", 1, NoSource())
+	logger.DebugNoSource("hot path", "id", 2)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 logs, got %d", len(lines))
+	}
+	if !strings.Contains(lines[0], "@logger_test.go:") {
+		t.Errorf("expected the source of the normal log, got %q", lines[0])
+	}
+	for _, line := range lines[1:] {
+		if strings.Contains(line, "@") || strings.Contains(line, NO_SOURCE_KEY) {
+			t.Errorf("expected no source nor sentinel attribute, got %q", line)
+		}
+	}
+	if !strings.HasSuffix(lines[1], "suppressed id=1") || !strings.HasSuffix(lines[2], "hot path id=2") {
+		t.Errorf("unexpected suppressed logs %q", lines[1:])
+	}
+}
+
+func TestJsonSourceObject(t *testing.T) {
+	jsonBuf := &bytes.Buffer{}
+	logger := NewCustomLogger(io.Discard, &CustomHandlerOptions{AddSource: true, JsonWriter: jsonBuf})
+	_, _, line, _ := runtime.Caller(0)
+	logger.Info("structured source")
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(jsonBuf.Bytes(), &payload); err != nil {
+		t.Fatalf("invalid json log : %s", err)
+	}
+	source, ok := payload["source"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected the source to be an object, got %v", payload["source"])
+	}
+	if l, ok := source["line"].(float64); !ok || int(l) != line+1 {
+		t.Errorf("expected the source line to be the number %d, got %#v", line+1, source["line"])
+	}
+	if file, _ := source["file"].(string); filepath.Base(file) != "logger_test.go" {
+		t.Errorf("expected the source file, got %v", source["file"])
+	}
+	if function, _ := source["function"].(string); !strings.HasSuffix(function, ".TestJsonSourceObject") {
+		t.Errorf("expected the source function, got %v", source["function"])
+	}
+
+	jsonBuf.Reset()
+	NewCustomLogger(io.Discard, &CustomHandlerOptions{AddSource: false, JsonWriter: jsonBuf}).Info("no source")
+	if strings.Contains(jsonBuf.String(), `"source"`) {
+		t.Errorf("expected no source without AddSource, got %q", jsonBuf.String())
+	}
+}
+
+func TestNoTime(t *testing.T) {
+	timestamp := regexp.MustCompile(`\d{4}-\d{2}-\d{2}`)
+	for _, format := range []TextFormat{FormatBanner, FormatCompact, FormatJSON} {
+		buf := &bytes.Buffer{}
+		jsonBuf := &bytes.Buffer{}
+		handler := NewCustomLogger(buf, &CustomHandlerOptions{Format: format, NoTime: true, JsonWriter: jsonBuf}).Handler()
+		handler.Handle(context.Background(), goldenRecord(slog.LevelInfo, "untimed", slog.Int("id", 5)))
+
+		for _, output := range []string{buf.String(), jsonBuf.String()} {
+			if timestamp.MatchString(output) || strings.Contains(output, `"time"`) {
+				t.Errorf("expected no timestamp, got %q", output)
+			}
+			if !strings.Contains(output, "untimed") {
+				t.Errorf("expected the log, got %q", output)
+			}
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	handler := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, NoTime: true}).Handler()
+	handler.Handle(context.Background(), goldenRecord(slog.LevelInfo, "compact", slog.Int("id", 5)))
+	if buf.String() != "INFO compact id=5\n" {
+		t.Errorf("unexpected compact log without time %q", buf.String())
+	}
+
+	//a time attribute re-added by ReplaceAttr keeps its key
+	jsonBuf := &bytes.Buffer{}
+	NewCustomLogger(io.Discard, &CustomHandlerOptions{NoTime: true, JsonWriter: jsonBuf, ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key == "at" {
+			return slog.String("time", "journald")
+		}
+		return a
+	}}).Info("re-added", "at", "now")
+	if !strings.Contains(jsonBuf.String(), `"time":"journald"`) {
+		t.Errorf("expected the time attribute re-added, got %q", jsonBuf.String())
+	}
+}
+
+func TestNowClock(t *testing.T) {
+	frozen := time.Date(2024, 5, 17, 14, 30, 0, 0, time.UTC)
+	buf := &bytes.Buffer{}
+	jsonBuf := &bytes.Buffer{}
+	handler := NewCustomLogger(buf, &CustomHandlerOptions{
+		Format:     FormatCompact,
+		JsonWriter: jsonBuf,
+		Now:        func() time.Time { return frozen },
+	}).Handler()
+
+	handler.Handle(context.Background(), slog.NewRecord(time.Time{}, slog.LevelInfo, "frozen", 0))
+	if buf.String() != "INFO 2024-05-17 14:30:00 frozen\n" {
+		t.Errorf("expected the time of the clock, got %q", buf.String())
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(jsonBuf.Bytes(), &payload); err != nil {
+		t.Fatalf("invalid json log : %s", err)
+	}
+	if payload["time"] != "2024-05-17 14:30:00" {
+		t.Errorf("expected the json time of the clock, got %v", payload["time"])
+	}
+
+	//the records with a time keep it
+	buf.Reset()
+	handler.Handle(context.Background(), slog.NewRecord(frozen.Add(time.Hour), slog.LevelInfo, "timed", 0))
+	if !strings.Contains(buf.String(), "2024-05-17 15:30:00") {
+		t.Errorf("expected the time of the record, got %q", buf.String())
+	}
+}
+
+func TestStaticFields(t *testing.T) {
+	buf := &bytes.Buffer{}
+	jsonBuf := &bytes.Buffer{}
+	fields := DefaultStaticFields("1.4.2")
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, JsonWriter: jsonBuf, StaticFields: fields})
+	fields["service_version"] = "changed"
+
+	logger.Named("db").WithGroup("query").With("table", "users").Info("derived", "rows", 3)
+
+	pid := strconv.Itoa(os.Getpid())
+	if !strings.Contains(buf.String(), " host="+fields["host"].(string)+" pid="+pid+" service_version=1.4.2 query.table=users query.rows=3") {
+		t.Errorf("expected the static fields at the root of the derived logger, got %q", buf.String())
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(jsonBuf.Bytes(), &payload); err != nil {
+		t.Fatalf("invalid json log : %s", err)
+	}
+	if fmt.Sprint(payload["pid"]) != pid || payload["service_version"] != "1.4.2" || payload["host"] == nil {
+		t.Errorf("expected the static fields in the json log, got %v", payload)
+	}
+
+	//the other attributes override the static fields
+	buf.Reset()
+	logger.Info("overridden", "service_version", "2.0.0")
+	if strings.Contains(buf.String(), "1.4.2") || !strings.Contains(buf.String(), "service_version=2.0.0") {
+		t.Errorf("expected the static field to be overridden, got %q", buf.String())
+	}
+}
+
+func TestSinkMinimumLevels(t *testing.T) {
+	for _, test := range []struct {
+		textLevel, jsonLevel slog.Level
+		text, json           bool
+	}{
+		{slog.LevelDebug, slog.LevelDebug, true, true},
+		{slog.LevelDebug, slog.LevelWarn, true, false},
+		{slog.LevelWarn, slog.LevelDebug, false, true},
+		{slog.LevelWarn, slog.LevelWarn, false, false},
+	} {
+		buf := &bytes.Buffer{}
+		jsonBuf := &bytes.Buffer{}
+		logger := NewCustomLogger(buf, &CustomHandlerOptions{
+			Format:           FormatCompact,
+			JsonWriter:       jsonBuf,
+			MinimumLevel:     slog.LevelError,
+			TextMinimumLevel: test.textLevel,
+			JsonMinimumLevel: test.jsonLevel,
+		})
+		name := fmt.Sprintf("text %s, json %s", test.textLevel, test.jsonLevel)
+
+		if enabled := logger.Handler().Enabled(context.Background(), slog.LevelInfo); enabled != (test.text || test.json) {
+			t.Errorf("%s : expected Enabled() to be %v for Info, got %v", name, test.text || test.json, enabled)
+		}
+		logger.Info("routed")
+		if logged := buf.Len() != 0; logged != test.text {
+			t.Errorf("%s : expected the text log %v, got %q", name, test.text, buf.String())
+		}
+		if logged := jsonBuf.Len() != 0; logged != test.json {
+			t.Errorf("%s : expected the json log %v, got %q", name, test.json, jsonBuf.String())
+		}
+	}
+}
+
+func TestAddScope(t *testing.T) {
+	jsonBuf := &bytes.Buffer{}
+	logger := NewCustomLogger(io.Discard, &CustomHandlerOptions{JsonWriter: jsonBuf, AddScope: true})
+	logger.WithGroup("a").WithGroup("b").Info("scoped", "id", 5)
+	logger.Info("root")
+
+	lines := strings.Split(strings.TrimSpace(jsonBuf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 json logs, got %q", jsonBuf.String())
+	}
+	var scoped, root map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &scoped); err != nil {
+		t.Fatalf("invalid json log : %s", err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &root); err != nil {
+		t.Fatalf("invalid json log : %s", err)
+	}
+	if scoped[SCOPE_KEY] != "a.b" {
+		t.Errorf("expected the scope a.b, got %v", scoped)
+	}
+	if a, _ := scoped["a"].(map[string]interface{}); a["b"] == nil {
+		t.Errorf("expected the attributes to stay nested, got %v", scoped)
+	}
+	if _, ok := root[SCOPE_KEY]; ok {
+		t.Errorf("expected no scope without group, got %v", root)
+	}
+}
+
+func TestAddContextDeadline(t *testing.T) {
+	handler := NewMemoryHandler(&CustomHandlerOptions{AddContextDeadline: true})
+	logger := slog.New(handler)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	logger.InfoContext(ctx, "first")
+	time.Sleep(10 * time.Millisecond)
+	logger.InfoContext(ctx, "second")
+	logger.InfoContext(context.Background(), "no deadline")
+
+	records := handler.Records()
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+	first, _ := records[0].Attr(DEADLINE_REMAINING_KEY)
+	second, _ := records[1].Attr(DEADLINE_REMAINING_KEY)
+	if first.Kind() != slog.KindDuration || first.Duration() <= 0 || first.Duration() > time.Minute {
+		t.Errorf("expected a positive remaining duration, got %v", first)
+	}
+	if second.Kind() != slog.KindDuration || second.Duration() <= 0 || second.Duration() >= first.Duration() {
+		t.Errorf("expected the remaining duration to decrease, got %v then %v", first, second)
+	}
+	if _, ok := records[2].Attr(DEADLINE_REMAINING_KEY); ok {
+		t.Errorf("expected no remaining duration without deadline, got %v", records[2].Attrs)
+	}
+}
+
+func TestAddGoroutineID(t *testing.T) {
+	handler := NewMemoryHandler(&CustomHandlerOptions{AddGoroutineID: true})
+	logger := slog.New(handler)
+
+	logger.Info("main goroutine")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		logger.Info("other goroutine")
+	}()
+	<-done
+	logger.Info("main goroutine again")
+
+	records := handler.Records()
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+	ids := make([]uint64, 0, len(records))
+	for _, record := range records {
+		id, ok := record.Attr(GOROUTINE_KEY)
+		if !ok || id.Kind() != slog.KindUint64 || id.Uint64() == 0 {
+			t.Fatalf("expected a goroutine id in %q, got %v", record.Message, id)
+		}
+		ids = append(ids, id.Uint64())
+	}
+	if ids[0] == ids[1] || ids[0] != ids[2] {
+		t.Errorf("expected the ids of the two goroutines to differ, got %v", ids)
+	}
+}
+
+func TestMaxMessageLength(t *testing.T) {
+	buf := &bytes.Buffer{}
+	jsonBuf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, MaxMessageLength: 10, JsonWriter: jsonBuf})
+
+	for _, test := range []struct {
+		message   string
+		logged    string
+		truncated bool
+	}{
+		{"0123456789", "0123456789", false},
+		{"0123456789A", "0123456789…", true},
+		//"é" is encoded on the 10th and 11th bytes
+		{"012345678é", "012345678…", true},
+		{"01234567é", "01234567é", false},
+	} {
+		buf.Reset()
+		jsonBuf.Reset()
+		logger.Info(test.message)
+
+		expected := " " + test.logged + "\n"
+		if test.truncated {
+			expected = " " + test.logged + " " + MESSAGE_TRUNCATED_KEY + "=true\n"
+		}
+		if !strings.HasSuffix(buf.String(), expected) {
+			t.Errorf("expected %q logged as %q, got %q", test.message, test.logged, buf.String())
+		}
+		var payload map[string]interface{}
+		if err := json.Unmarshal(jsonBuf.Bytes(), &payload); err != nil {
+			t.Fatalf("invalid json log : %s", err)
+		}
+		if _, flagged := payload[MESSAGE_TRUNCATED_KEY]; payload["msg"] != test.logged || flagged != test.truncated {
+			t.Errorf("unexpected json log of %q : %v", test.message, payload)
+		}
+	}
+}
+
+func TestJsonMaxValueLength(t *testing.T) {
+	buf := &bytes.Buffer{}
+	jsonBuf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, JsonWriter: jsonBuf, JsonMaxValueLength: 8})
+	logger.Info("truncated", "body", "0123456789", "name", "bob", slog.Group("req", slog.String("agent", "curl/8.1.2é")))
+
+	if !strings.HasSuffix(buf.String(), " truncated body=0123456789 name=bob req.agent=curl/8.1.2é\n") {
+		t.Errorf("expected the full values in the text log, got %q", buf.String())
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(jsonBuf.Bytes(), &payload); err != nil {
+		t.Fatalf("invalid json log : %s", err)
+	}
+	for key, expected := range map[string]interface{}{
+		"body":           "01234567…",
+		"body_truncated": true,
+		"name":           "bob",
+		"req":            map[string]interface{}{"agent": "curl/8.1…", "agent_truncated": true},
+	} {
+		if !reflect.DeepEqual(payload[key], expected) {
+			t.Errorf("expected the json field %s = %#v, got %#v", key, expected, payload[key])
+		}
+	}
+	if _, ok := payload["name_truncated"]; ok {
+		t.Errorf("unexpected truncation marker of a short value : %v", payload)
+	}
+}
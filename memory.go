@@ -0,0 +1,119 @@
+package customsloglogger
+
+import (
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CapturedRecord is a record captured by a MemoryHandler
+type CapturedRecord struct {
+	Time      time.Time
+	Level     slog.Level
+	Message   string
+	Component string
+	//Attrs are the attributes of the record, as they would be logged
+	//(after ReplaceAttr and redaction), nested in their groups
+	Attrs []slog.Attr
+}
+
+// Attr(key) returns the value of the attribute of the record.
+// The attributes of a group are accessed with a dotted key (e.g. "request.method")
+func (r CapturedRecord) Attr(key string) (slog.Value, bool) {
+	attrs := r.Attrs
+	path := strings.Split(key, ".")
+	for i, name := range path {
+		index := slices.IndexFunc(attrs, func(a slog.Attr) bool { return a.Key == name })
+		if index < 0 {
+			return slog.Value{}, false
+		}
+		if i == len(path)-1 {
+			return attrs[index].Value, true
+		}
+		if attrs[index].Value.Kind() != slog.KindGroup {
+			return slog.Value{}, false
+		}
+		attrs = attrs[index].Value.Group()
+	}
+	return slog.Value{}, false
+}
+
+// recordStore stores the records captured by a MemoryHandler and the handlers derived from it
+type recordStore struct {
+	mu      sync.Mutex
+	records []CapturedRecord
+}
+
+// add(hr, component) captures the prepared record
+func (s *recordStore) add(hr *handledRecord, component string) {
+	record := CapturedRecord{
+		Time:      hr.Time,
+		Level:     hr.Level,
+		Message:   hr.Message,
+		Component: component,
+		Attrs:     make([]slog.Attr, 0, len(hr.attrs)),
+	}
+	for _, attr := range hr.attrs {
+		record.Attrs = nestAttr(record.Attrs, attr.groups, attr.Attr)
+	}
+
+	s.mu.Lock()
+	s.records = append(s.records, record)
+	s.mu.Unlock()
+}
+
+// nestAttr(attrs, groups, a) appends the attribute to the attributes, nested in its group path
+func nestAttr(attrs []slog.Attr, groups []string, a slog.Attr) []slog.Attr {
+	if len(groups) == 0 {
+		return append(attrs, a)
+	}
+	for i := range attrs {
+		if attrs[i].Key == groups[0] && attrs[i].Value.Kind() == slog.KindGroup {
+			members := slices.Clone(attrs[i].Value.Group())
+			attrs[i].Value = slog.GroupValue(nestAttr(members, groups[1:], a)...)
+			return attrs
+		}
+	}
+	return append(attrs, slog.Attr{Key: groups[0], Value: slog.GroupValue(nestAttr(nil, groups[1:], a)...)})
+}
+
+// MemoryHandler is a CustomHandler capturing its records in memory instead of writing them,
+// so that tests can assert on the level, message and attributes of the logs.
+// The handlers derived from it (With(), WithGroup(), Named()...) capture their records
+// in the same MemoryHandler
+type MemoryHandler struct {
+	*CustomHandler
+}
+
+// NewMemoryHandler() creates a new *MemoryHandler with the options
+// (the same defaults as NewCustomHandler() if nil). No text log is rendered (even on the LevelWriters
+// or with WithWriter()), but the json logs are still sent if the JsonLogURL or JsonWriter options are defined
+func NewMemoryHandler(options *CustomHandlerOptions) *MemoryHandler {
+	handler := NewCustomHandler(io.Discard, options)
+	handler.memory = &recordStore{}
+	return &MemoryHandler{handler}
+}
+
+// NewCaptureLogger() creates a new *CustomLogger capturing its records
+// in the returned *MemoryHandler
+func NewCaptureLogger(options *CustomHandlerOptions) (*CustomLogger, *MemoryHandler) {
+	handler := NewMemoryHandler(options)
+	return &CustomLogger{slog.New(handler.CustomHandler)}, handler
+}
+
+// Records() returns a copy of the records captured so far
+func (m *MemoryHandler) Records() []CapturedRecord {
+	m.memory.mu.Lock()
+	defer m.memory.mu.Unlock()
+	return slices.Clone(m.memory.records)
+}
+
+// Reset() removes the records captured so far
+func (m *MemoryHandler) Reset() {
+	m.memory.mu.Lock()
+	defer m.memory.mu.Unlock()
+	m.memory.records = nil
+}
@@ -0,0 +1,82 @@
+package customsloglogger
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestCaptureLogger(t *testing.T) {
+	logger, memory := NewCaptureLogger(&CustomHandlerOptions{MinimumLevel: slog.LevelInfo, RedactKeys: []string{"password"}})
+
+	logger.Debug("filtered")
+	logger.With("user", "alice").Named("auth").WithGroup("request").Info("login", "method", "POST", "password", "secret")
+	logger.Warn("slow", "elapsed", 42)
+
+	records := memory.Records()
+	if len(records) != 2 {
+		t.Fatalf("expected 2 captured records, got %d", len(records))
+	}
+
+	login := records[0]
+	if login.Level != slog.LevelInfo || login.Message != "login" || login.Component != "auth" {
+		t.Errorf("unexpected captured record %+v", login)
+	}
+	if login.Time.IsZero() {
+		t.Errorf("expected the time of the record to be captured")
+	}
+	if v, ok := login.Attr("user"); !ok || v.String() != "alice" {
+		t.Errorf("expected user=alice, got %v (%t)", v, ok)
+	}
+	if v, ok := login.Attr("request.method"); !ok || v.String() != "POST" {
+		t.Errorf("expected request.method=POST, got %v (%t)", v, ok)
+	}
+	if v, ok := login.Attr("request.password"); !ok || v.String() != REDACTED_VALUE {
+		t.Errorf("expected a redacted password, got %v (%t)", v, ok)
+	}
+	if _, ok := login.Attr("method"); ok {
+		t.Errorf("expected the method to be grouped in request")
+	}
+
+	if v, ok := records[1].Attr("elapsed"); !ok || v.Int64() != 42 {
+		t.Errorf("expected elapsed=42, got %v (%t)", v, ok)
+	}
+
+	memory.Reset()
+	if len(memory.Records()) != 0 {
+		t.Errorf("expected no record after Reset")
+	}
+}
+
+func TestMemoryHandlerWithSlog(t *testing.T) {
+	memory := NewMemoryHandler(nil)
+	logger := slog.New(memory).With("service", "api")
+	logger.Info("started", "port", 8080)
+
+	records := memory.Records()
+	if len(records) != 1 {
+		t.Fatalf("expected 1 captured record, got %d", len(records))
+	}
+	if v, ok := records[0].Attr("service"); !ok || v.String() != "api" {
+		t.Errorf("expected service=api, got %v (%t)", v, ok)
+	}
+	if v, ok := records[0].Attr("port"); !ok || v.Int64() != 8080 {
+		t.Errorf("expected port=8080, got %v (%t)", v, ok)
+	}
+}
+
+func TestMemoryHandlerNoText(t *testing.T) {
+	levelWriter := &bytes.Buffer{}
+	writer := &bytes.Buffer{}
+	logger, memory := NewCaptureLogger(&CustomHandlerOptions{LevelWriters: map[slog.Level]io.Writer{slog.LevelInfo: levelWriter}})
+	logger.Info("captured")
+	logger.WithWriter(writer).Warn("captured too")
+
+	if len(memory.Records()) != 2 {
+		t.Fatalf("expected 2 captured records, got %d", len(memory.Records()))
+	}
+	if levelWriter.Len() != 0 || writer.Len() != 0 {
+		t.Errorf("expected no text log, got %q and %q", levelWriter.String(), writer.String())
+	}
+}
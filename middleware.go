@@ -0,0 +1,48 @@
+package customsloglogger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// RecoveryMiddleware(next) returns an http.Handler recovering the panics of next :
+// the panic is logged at the Error level with the request context (for the context attributes),
+// the request (see RequestAttrs()) and the stack trace, its json log being delivered
+// (at most JsonTimeout) before a 500 Internal Server Error response is returned.
+// The http.ErrAbortHandler panics, which abort the response on purpose, are not recovered
+func (c *CustomLogger) RecoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+			c.logPanic(r.Context(), slog.LevelError, "panic recovered",
+				slog.String("panic", fmt.Sprint(recovered)),
+				RequestAttrs(r),
+				slog.String(STACKTRACE_KEY, callerStacktrace()))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// logPanic(ctx, level, msg, attrs) logs the record of a panic, waiting for the delivery of its json logs :
+// the record is handled by a copy of the handler with the SyncJson option
+func (c *CustomLogger) logPanic(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	h := c.Handler()
+	if h == nil {
+		c.Logger.LogAttrs(ctx, level, msg, attrs...)
+		c.Sync()
+		return
+	}
+	syncHandler := h.Clone()
+	syncHandler.generatedID = h.generatedID
+	syncHandler.Options.SyncJson = true
+	slog.New(syncHandler).LogAttrs(ctx, level, msg, attrs...)
+}
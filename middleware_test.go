@@ -0,0 +1,69 @@
+package customsloglogger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	var mu sync.Mutex
+	var bodies [][]byte
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		bodies = append(bodies, body)
+	}))
+	defer collector.Close()
+
+	buf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, AddSource: true, JsonLogURL: collector.URL, JsonTimeout: time.Second}).
+		WithCtxAttrsKeys([]string{"request_id"})
+	handler := logger.RecoveryMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("nil map")
+	}))
+
+	r := httptest.NewRequest("GET", "/orders", nil)
+	r = r.WithContext(context.WithValue(r.Context(), CtxKeyString("request_id"), "req-42"))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected a 500 response, got %d", w.Code)
+	}
+	if !strings.HasPrefix(buf.String(), "ERROR ") || !strings.Contains(buf.String(), "middleware_test.go") || !strings.Contains(buf.String(), "panic recovered panic=\"nil map\"") {
+		t.Errorf("expected an Error log of the panic, got %q", buf.String())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 1 {
+		t.Fatalf("expected the json log to be delivered before the response, got %d", len(bodies))
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(bodies[0], &payload); err != nil {
+		t.Fatalf("invalid json log : %s", err)
+	}
+	if payload["request_id"] != "req-42" || payload["level"] != "ERROR" {
+		t.Errorf("expected the context attribute in the Error log, got %v", payload)
+	}
+	if request, _ := payload[REQUEST_KEY].(map[string]interface{}); request["path"] != "/orders" {
+		t.Errorf("expected the request in the log, got %v", payload)
+	}
+	if stacktrace, _ := payload[STACKTRACE_KEY].(string); !strings.Contains(stacktrace, "TestRecoveryMiddleware") {
+		t.Errorf("expected the stack trace of the panic, got %q", stacktrace)
+	}
+	if source, _ := payload["source"].(map[string]interface{}); !strings.HasSuffix(fmt.Sprint(source["file"]), "middleware_test.go") {
+		t.Errorf("expected the panicking handler as source, got %v", payload["source"])
+	}
+}
@@ -0,0 +1,175 @@
+package customsloglogger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// PayloadFormat is the serialization of the logs sent to JsonLogURL
+type PayloadFormat int
+
+// Here are the payload formats of the logs sent to JsonLogURL (see PayloadFormat option)
+const (
+	//PayloadJSON serializes the logs as json ("application/json")
+	PayloadJSON PayloadFormat = iota
+	//PayloadMsgpack serializes the logs as MessagePack ("application/msgpack"), more compact than json
+	PayloadMsgpack
+)
+
+// MSGPACK_CONTENT_TYPE is the Content-Type of the logs sent with the PayloadMsgpack format
+const MSGPACK_CONTENT_TYPE = "application/msgpack"
+
+// msgpackLog(hr) returns the MessagePack log of the record, checked by the JsonValidate option
+// and limited to the MaxJsonBytes option, as the json log (see jsonLog()), with the same typed values.
+// It returns nil if the log exceeds MaxJsonBytes even without its attributes
+func (m *CustomHandler) msgpackLog(hr *handledRecord) ([]byte, error) {
+	return m.payloadLog(hr, m.jsonValue, marshalMsgpack)
+}
+
+// marshalMsgpack(v) returns the MessagePack encoding of v. The maps are encoded with sorted keys.
+// The values without MessagePack type (e.g. structs) are encoded as their json representation
+func marshalMsgpack(v any) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := encodeMsgpack(buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeMsgpack(buf, v) writes the MessagePack encoding of v on buf
+func encodeMsgpack(buf *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case int:
+		encodeMsgpackInt(buf, int64(v))
+	case int64:
+		encodeMsgpackInt(buf, v)
+	case uint64:
+		encodeMsgpackUint(buf, v)
+	case float64:
+		buf.WriteByte(0xcb)
+		buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(v)))
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			encodeMsgpackInt(buf, i)
+		} else if f, err := v.Float64(); err == nil {
+			return encodeMsgpack(buf, f)
+		} else {
+			return fmt.Errorf("invalid number %s : %w", v, err)
+		}
+	case string:
+		encodeMsgpackHeader(buf, len(v), 0xa0, 32, 0xd9, 0xda, 0xdb)
+		buf.WriteString(v)
+	case []byte:
+		encodeMsgpackHeader(buf, len(v), 0, 0, 0xc4, 0xc5, 0xc6)
+		buf.Write(v)
+	case []string:
+		encodeMsgpackHeader(buf, len(v), 0x90, 16, 0, 0xdc, 0xdd)
+		for _, s := range v {
+			encodeMsgpack(buf, s)
+		}
+	case []interface{}:
+		encodeMsgpackHeader(buf, len(v), 0x90, 16, 0, 0xdc, 0xdd)
+		for _, item := range v {
+			if err := encodeMsgpack(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		encodeMsgpackHeader(buf, len(v), 0x80, 16, 0, 0xde, 0xdf)
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			encodeMsgpack(buf, key)
+			if err := encodeMsgpack(buf, v[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		//the other values (e.g. slices, maps and structs of the attributes) are encoded as their json representation
+		jsonByte, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("unable to encode %T in msgpack : %w", v, err)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(jsonByte))
+		decoder.UseNumber()
+		var decoded interface{}
+		if err := decoder.Decode(&decoded); err != nil {
+			return fmt.Errorf("unable to encode %T in msgpack : %w", v, err)
+		}
+		return encodeMsgpack(buf, decoded)
+	}
+	return nil
+}
+
+// encodeMsgpackInt(buf, i) writes the shortest MessagePack encoding of the integer i
+func encodeMsgpackInt(buf *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0:
+		encodeMsgpackUint(buf, uint64(i))
+	case i >= -32:
+		buf.WriteByte(byte(i))
+	case i >= math.MinInt8:
+		buf.Write([]byte{0xd0, byte(i)})
+	case i >= math.MinInt16:
+		buf.WriteByte(0xd1)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(i)))
+	case i >= math.MinInt32:
+		buf.WriteByte(0xd2)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(i)))
+	default:
+		buf.WriteByte(0xd3)
+		buf.Write(binary.BigEndian.AppendUint64(nil, uint64(i)))
+	}
+}
+
+// encodeMsgpackUint(buf, u) writes the shortest MessagePack encoding of the unsigned integer u
+func encodeMsgpackUint(buf *bytes.Buffer, u uint64) {
+	switch {
+	case u <= math.MaxInt8:
+		buf.WriteByte(byte(u))
+	case u <= math.MaxUint8:
+		buf.Write([]byte{0xcc, byte(u)})
+	case u <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(u)))
+	case u <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(u)))
+	default:
+		buf.WriteByte(0xcf)
+		buf.Write(binary.BigEndian.AppendUint64(nil, u))
+	}
+}
+
+// encodeMsgpackHeader(buf, n, fix, fixMax, code8, code16, code32) writes the header of a string,
+// a binary, an array or a map of length n : the fix code if n is under fixMax,
+// else the code of the shortest length (code8 being unused if 0)
+func encodeMsgpackHeader(buf *bytes.Buffer, n int, fix byte, fixMax int, code8, code16, code32 byte) {
+	switch {
+	case n < fixMax:
+		buf.WriteByte(fix | byte(n))
+	case code8 != 0 && n <= math.MaxUint8:
+		buf.Write([]byte{code8, byte(n)})
+	case n <= math.MaxUint16:
+		buf.WriteByte(code16)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		buf.WriteByte(code32)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+}
@@ -0,0 +1,200 @@
+package customsloglogger
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/darthyoh/custom-slog-logger/customslogloggertest"
+)
+
+// decodeMsgpack decodes the MessagePack value at the start of data, returning the remaining bytes.
+// The integers are decoded as int64 (uint64 beyond math.MaxInt64), the strings, arrays and maps as string, []any and map[string]any
+func decodeMsgpack(data []byte) (any, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	code, data := data[0], data[1:]
+	//length returns the big-endian length on n bytes
+	length := func(n int) (int, error) {
+		if len(data) < n {
+			return 0, io.ErrUnexpectedEOF
+		}
+		var l uint64
+		for _, b := range data[:n] {
+			l = l<<8 | uint64(b)
+		}
+		data = data[n:]
+		return int(l), nil
+	}
+	//collection decodes the n items of an array, or the n pairs of a map
+	collection := func(n int, isMap bool) (any, []byte, error) {
+		items, fields := []any{}, map[string]any{}
+		for i := 0; i < n; i++ {
+			var key, item any
+			var err error
+			if isMap {
+				if key, data, err = decodeMsgpack(data); err != nil {
+					return nil, nil, err
+				}
+			}
+			if item, data, err = decodeMsgpack(data); err != nil {
+				return nil, nil, err
+			}
+			if isMap {
+				fields[fmt.Sprint(key)] = item
+			} else {
+				items = append(items, item)
+			}
+		}
+		if isMap {
+			return fields, data, nil
+		}
+		return items, data, nil
+	}
+	//raw returns the next n bytes
+	raw := func(n int, err error) ([]byte, []byte, error) {
+		if err != nil || len(data) < n {
+			return nil, nil, io.ErrUnexpectedEOF
+		}
+		return data[:n], data[n:], nil
+	}
+
+	switch {
+	case code <= 0x7f:
+		return int64(code), data, nil
+	case code >= 0xe0:
+		return int64(int8(code)), data, nil
+	case code&0xe0 == 0xa0:
+		s, rest, err := raw(int(code&0x1f), nil)
+		return string(s), rest, err
+	case code&0xf0 == 0x90:
+		return collection(int(code&0x0f), false)
+	case code&0xf0 == 0x80:
+		return collection(int(code&0x0f), true)
+	}
+	switch code {
+	case 0xc0:
+		return nil, data, nil
+	case 0xc2, 0xc3:
+		return code == 0xc3, data, nil
+	case 0xcb:
+		b, rest, err := raw(8, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), rest, nil
+	case 0xcc, 0xcd, 0xce:
+		n, err := length(1 << (code - 0xcc))
+		return int64(n), data, err
+	case 0xcf:
+		b, rest, err := raw(8, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		u := binary.BigEndian.Uint64(b)
+		if u > math.MaxInt64 {
+			return u, rest, nil
+		}
+		return int64(u), rest, nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (code - 0xd0)
+		b, rest, err := raw(size, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		var u uint64
+		for _, c := range b {
+			u = u<<8 | uint64(c)
+		}
+		//sign extension of the size bytes integer
+		shift := 64 - 8*size
+		return int64(u<<shift) >> shift, rest, nil
+	case 0xd9, 0xda, 0xdb:
+		s, rest, err := raw(length(1 << (code - 0xd9)))
+		return string(s), rest, err
+	case 0xc4, 0xc5, 0xc6:
+		return raw(length(1 << (code - 0xc4)))
+	case 0xdc, 0xdd:
+		n, err := length(2 << (code - 0xdc))
+		if err != nil {
+			return nil, nil, err
+		}
+		return collection(n, false)
+	case 0xde, 0xdf:
+		n, err := length(2 << (code - 0xde))
+		if err != nil {
+			return nil, nil, err
+		}
+		return collection(n, true)
+	}
+	return nil, nil, fmt.Errorf("unsupported msgpack code 0x%x", code)
+}
+
+func TestMarshalMsgpack(t *testing.T) {
+	for _, value := range []any{
+		nil, true, false, int64(5), int64(-5), int64(200), int64(-200), int64(70000), int64(-70000),
+		int64(math.MaxInt64), int64(math.MinInt64), uint64(math.MaxUint64), 3.5,
+		"short", string(make([]byte, 40)), string(make([]byte, 300)),
+		[]any{int64(1), "two", []any{}}, map[string]any{"a": int64(1), "b": map[string]any{"c": nil}},
+	} {
+		encoded, err := marshalMsgpack(value)
+		if err != nil {
+			t.Fatalf("unable to encode %#v : %s", value, err)
+		}
+		decoded, rest, err := decodeMsgpack(encoded)
+		if err != nil || len(rest) != 0 {
+			t.Fatalf("unable to decode %#v : %v (%d bytes left)", value, err, len(rest))
+		}
+		if !reflect.DeepEqual(decoded, value) {
+			t.Errorf("expected %#v, decoded %#v", value, decoded)
+		}
+	}
+}
+
+func TestPayloadMsgpack(t *testing.T) {
+	server, serverURL := customslogloggertest.NewCaptureServer()
+	defer server.Close()
+	logger := NewCustomLogger(io.Discard, &CustomHandlerOptions{JsonLogURL: serverURL, PayloadFormat: PayloadMsgpack, SyncJson: true})
+	logger.Info("msgpack", "count", 3, "ratio", 0.5, "ok", true, "user", "bob",
+		slog.Group("req", slog.Uint64("size", 512), slog.Duration("elapsed", time.Second)),
+		"ids", []int{1, 2}, "err", errors.New("failed"))
+
+	bodies := server.Bodies()
+	if len(bodies) != 1 {
+		t.Fatalf("expected 1 msgpack payload, got %d", len(bodies))
+	}
+	if contentType := server.Headers()[0].Get("Content-Type"); contentType != MSGPACK_CONTENT_TYPE {
+		t.Errorf("expected the %s content type, got %q", MSGPACK_CONTENT_TYPE, contentType)
+	}
+	decoded, rest, err := decodeMsgpack(bodies[0])
+	if err != nil || len(rest) != 0 {
+		t.Fatalf("invalid msgpack payload : %v (%d bytes left)", err, len(rest))
+	}
+	payload, _ := decoded.(map[string]any)
+	for key, expected := range map[string]any{
+		"msg":   "msgpack",
+		"level": "INFO",
+		"count": int64(3),
+		"ratio": 0.5,
+		"ok":    true,
+		"user":  "bob",
+		"req":   map[string]any{"size": int64(512), "elapsed": "1s"},
+		"ids":   []any{int64(1), int64(2)},
+		"err":   []any{"failed"},
+	} {
+		if !reflect.DeepEqual(payload[key], expected) {
+			t.Errorf("expected the msgpack field %s = %#v, got %#v", key, expected, payload[key])
+		}
+	}
+
+	if err := (&CustomHandlerOptions{PayloadFormat: PayloadMsgpack + 1}).Validate(); err == nil {
+		t.Errorf("expected an invalid PayloadFormat to be rejected")
+	}
+}
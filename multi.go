@@ -0,0 +1,87 @@
+package customsloglogger
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// MultiHandler is a slog.Handler fanning out the records to several handlers
+// (e.g. a CustomHandler for the console and another one for a file)
+type MultiHandler struct {
+	handlers []slog.Handler
+}
+
+// NewMultiHandler() creates a new *MultiHandler fanning out the records to the handlers
+func NewMultiHandler(handlers ...slog.Handler) *MultiHandler {
+	return &MultiHandler{handlers: handlers}
+}
+
+// Enabled : interface Handler method.
+// True is returned if at least one of the handlers is enabled for the level
+func (m *MultiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range m.handlers {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle : interface Handler method.
+// The record is handled by every handler enabled for its level, and their errors are joined
+func (m *MultiHandler) Handle(ctx context.Context, r slog.Record) error {
+	errs := []error{}
+	for _, h := range m.handlers {
+		if h.Enabled(ctx, r.Level) {
+			if err := h.Handle(ctx, r.Clone()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WithAttrs : interface Handler method, returning a new MultiHandler of the handlers with the attributes
+func (m *MultiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make([]slog.Handler, 0, len(m.handlers))
+	for _, h := range m.handlers {
+		handlers = append(handlers, h.WithAttrs(attrs))
+	}
+	return &MultiHandler{handlers: handlers}
+}
+
+// WithGroup : interface Handler method, returning a new MultiHandler of the handlers with the group
+func (m *MultiHandler) WithGroup(name string) slog.Handler {
+	handlers := make([]slog.Handler, 0, len(m.handlers))
+	for _, h := range m.handlers {
+		handlers = append(handlers, h.WithGroup(name))
+	}
+	return &MultiHandler{handlers: handlers}
+}
+
+// Sync() syncs every handler implementing a Sync() method (like CustomHandler) and joins their errors
+func (m *MultiHandler) Sync() error {
+	errs := []error{}
+	for _, h := range m.handlers {
+		if s, ok := h.(interface{ Sync() error }); ok {
+			if err := s.Sync(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close() closes every handler implementing a Close(ctx) method (like CustomHandler) and joins their errors
+func (m *MultiHandler) Close(ctx context.Context) error {
+	errs := []error{}
+	for _, h := range m.handlers {
+		if c, ok := h.(interface{ Close(context.Context) error }); ok {
+			if err := c.Close(ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
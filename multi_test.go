@@ -0,0 +1,74 @@
+package customsloglogger
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// syncWriter is a writer recording the calls of its Sync() method
+type syncWriter struct {
+	bytes.Buffer
+	synced int
+	err    error
+}
+
+func (w *syncWriter) Sync() error {
+	w.synced++
+	return w.err
+}
+
+// flushWriter is a writer recording the calls of its Flush() method
+type flushWriter struct {
+	bytes.Buffer
+	flushed int
+}
+
+func (w *flushWriter) Flush() error {
+	w.flushed++
+	return nil
+}
+
+func TestSync(t *testing.T) {
+	text := &syncWriter{}
+	json := &flushWriter{}
+	logger := NewCustomLogger(text, &CustomHandlerOptions{JsonWriter: json})
+	logger.Info("synced")
+
+	if err := logger.Sync(); err != nil {
+		t.Fatalf("unexpected error : %s", err)
+	}
+	if text.synced != 1 || json.flushed != 1 {
+		t.Errorf("expected the writers to be synced, got %d syncs and %d flushes", text.synced, json.flushed)
+	}
+}
+
+func TestMultiHandler(t *testing.T) {
+	console := &syncWriter{}
+	file := &syncWriter{err: errors.New("disk full")}
+	multi := NewMultiHandler(
+		NewCustomHandler(console, &CustomHandlerOptions{Format: FormatCompact, MinimumLevel: slog.LevelWarn}),
+		NewCustomHandler(file, &CustomHandlerOptions{Format: FormatCompact, MinimumLevel: slog.LevelDebug}),
+	)
+	logger := &CustomLogger{slog.New(multi)}
+
+	logger.WithGroup("req").With("id", 5).Debug("debug only")
+	logger.Warn("everywhere")
+
+	if output := console.String(); strings.Contains(output, "debug only") || !strings.Contains(output, "everywhere") {
+		t.Errorf("unexpected console logs %q", output)
+	}
+	if output := file.String(); !strings.Contains(output, "debug only req.id=5") || !strings.Contains(output, "everywhere") {
+		t.Errorf("unexpected file logs %q", output)
+	}
+
+	err := logger.Sync()
+	if console.synced != 1 || file.synced != 1 {
+		t.Errorf("expected Sync to fan out, got %d and %d syncs", console.synced, file.synced)
+	}
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("expected the sync error to be aggregated, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package customsloglogger
+
+import (
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+)
+
+// OTLP_SCOPE_NAME is the instrumentation scope name of the OTLP log records
+const OTLP_SCOPE_NAME = "github.com/darthyoh/custom-slog-logger"
+
+// otlpSeverity(level) returns the OpenTelemetry severity number of a log level :
+// 5 (DEBUG) for Debug, 9 (INFO) for Info, 13 (WARN) for Warn and 17 (ERROR) for Error,
+// the levels in between being mapped to the severities in between
+func otlpSeverity(level slog.Level) int {
+	return min(max(int(level)+9, 1), 24)
+}
+
+// otlpData(hr) returns the OTLP/HTTP json export request of the record :
+// a log record with its time, severity, message as body, and its attributes
+// (with their dotted group keys, like the semantic conventions), source and component
+func (m *CustomHandler) otlpData(hr *handledRecord) map[string]interface{} {
+	attributes := make([]interface{}, 0, len(hr.attrs)+2)
+	if hr.source != "" {
+		attributes = append(attributes, otlpKeyValue("source", slog.StringValue(hr.source)))
+	}
+	if m.Component != "" {
+		attributes = append(attributes, otlpKeyValue(COMPONENT_KEY, slog.StringValue(m.Component)))
+	}
+	for _, attr := range hr.attrs {
+		attributes = append(attributes, otlpKeyValue(attr.prefix+attr.Key, attr.Value))
+	}
+
+	timestamp := strconv.FormatInt(hr.Time.UnixNano(), 10)
+	record := map[string]interface{}{
+		"timeUnixNano":         timestamp,
+		"observedTimeUnixNano": timestamp,
+		"severityNumber":       otlpSeverity(hr.Level),
+		"severityText":         levelName(hr.Level),
+		"body":                 map[string]interface{}{"stringValue": hr.Message},
+		"attributes":           attributes,
+	}
+
+	return map[string]interface{}{
+		"resourceLogs": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{"attributes": []interface{}{}},
+			"scopeLogs": []interface{}{map[string]interface{}{
+				"scope":      map[string]interface{}{"name": OTLP_SCOPE_NAME},
+				"logRecords": []interface{}{record},
+			}},
+		}},
+	}
+}
+
+// otlpKeyValue(key, v) returns the OTLP json key-value of an attribute
+func otlpKeyValue(key string, v slog.Value) map[string]interface{} {
+	return map[string]interface{}{"key": key, "value": otlpValue(v)}
+}
+
+// otlpValue(v) returns the OTLP json any value of an attribute value.
+// Durations are represented by their number of nanoseconds, times by their RFC 3339 string,
+// groups by a key-value list, the values without OTLP type by their string
+func otlpValue(v slog.Value) map[string]interface{} {
+	switch v.Kind() {
+	case slog.KindBool:
+		return map[string]interface{}{"boolValue": v.Bool()}
+	case slog.KindInt64:
+		//64-bit integers are json strings in OTLP
+		return map[string]interface{}{"intValue": strconv.FormatInt(v.Int64(), 10)}
+	case slog.KindUint64:
+		//the OTLP integers are signed : the bigger ones are represented by their string
+		if v.Uint64() <= math.MaxInt64 {
+			return map[string]interface{}{"intValue": strconv.FormatUint(v.Uint64(), 10)}
+		}
+	case slog.KindFloat64:
+		if f := v.Float64(); !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return map[string]interface{}{"doubleValue": f}
+		}
+	case slog.KindDuration:
+		return map[string]interface{}{"intValue": strconv.FormatInt(v.Duration().Nanoseconds(), 10)}
+	case slog.KindTime:
+		return map[string]interface{}{"stringValue": v.Time().Format(time.RFC3339Nano)}
+	case slog.KindGroup:
+		values := make([]interface{}, 0, len(v.Group()))
+		for _, a := range v.Group() {
+			values = append(values, otlpKeyValue(a.Key, a.Value))
+		}
+		return map[string]interface{}{"kvlistValue": map[string]interface{}{"values": values}}
+	}
+	if err, ok := errorValue(v); ok {
+		return map[string]interface{}{"stringValue": err.Error()}
+	}
+	return map[string]interface{}{"stringValue": v.String()}
+}
@@ -0,0 +1,155 @@
+package customsloglogger
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darthyoh/custom-slog-logger/customslogloggertest"
+)
+
+func TestOTLPEndpoint(t *testing.T) {
+	collector, collectorURL := customslogloggertest.NewCaptureServer()
+	defer collector.Close()
+	logger := NewCustomLogger(io.Discard, &CustomHandlerOptions{OTLPEndpoint: collectorURL + "/v1/logs", MinimumLevel: slog.LevelDebug}).
+		Named("db").WithGroup("query")
+	logger.Debug("debug", "rows", 3)
+	logger.Warn("slow query", "rows", 3, "cached", false, "table", "users")
+	logger.Error("failed")
+
+	payloads := collector.Payloads()
+	if len(payloads) != 3 {
+		t.Fatalf("expected 3 otlp requests, got %d", len(payloads))
+	}
+
+	for i, expected := range []struct {
+		severity float64
+		text     string
+		body     string
+	}{{5, "DEBUG", "debug"}, {13, "WARN", "slow query"}, {17, "ERROR", "failed"}} {
+		resourceLogs := payloads[i]["resourceLogs"].([]interface{})[0].(map[string]interface{})
+		scopeLogs := resourceLogs["scopeLogs"].([]interface{})[0].(map[string]interface{})
+		record := scopeLogs["logRecords"].([]interface{})[0].(map[string]interface{})
+
+		if record["severityNumber"] != expected.severity || record["severityText"] != expected.text {
+			t.Errorf("expected the severity %v %s, got %v %v", expected.severity, expected.text, record["severityNumber"], record["severityText"])
+		}
+		if body := record["body"].(map[string]interface{}); body["stringValue"] != expected.body {
+			t.Errorf("expected the body %q, got %v", expected.body, body)
+		}
+		if i != 1 {
+			continue
+		}
+
+		attributes := map[string]interface{}{}
+		for _, kv := range record["attributes"].([]interface{}) {
+			kv := kv.(map[string]interface{})
+			attributes[kv["key"].(string)] = kv["value"]
+		}
+		for key, value := range map[string]map[string]interface{}{
+			"component":    {"stringValue": "db"},
+			"query.rows":   {"intValue": "3"},
+			"query.table":  {"stringValue": "users"},
+			"query.cached": {"boolValue": false},
+		} {
+			got, _ := attributes[key].(map[string]interface{})
+			for k, v := range value {
+				if got[k] != v {
+					t.Errorf("expected the attribute %s = %v, got %v", key, value, attributes[key])
+				}
+			}
+		}
+	}
+}
+
+func TestOTLPSeverity(t *testing.T) {
+	for level, expected := range map[slog.Level]int{
+		slog.LevelDebug: 5, slog.LevelInfo: 9, slog.LevelWarn: 13, slog.LevelError: 17,
+		slog.LevelInfo + 2: 11, slog.Level(-20): 1, slog.Level(30): 24,
+	} {
+		if severity := otlpSeverity(level); severity != expected {
+			t.Errorf("expected the severity %d for %s, got %d", expected, level, severity)
+		}
+	}
+}
+
+func TestOTLPIndependentOfJson(t *testing.T) {
+	collector, collectorURL := customslogloggertest.NewCaptureServer()
+	defer collector.Close()
+	logger := NewCustomLogger(io.Discard, &CustomHandlerOptions{
+		JsonWriter:           failingWriter{},
+		OTLPEndpoint:         collectorURL + "/v1/logs",
+		InternalErrorHandler: func(error) {},
+	})
+	logger.Info("disk full")
+
+	if payloads := collector.Payloads(); len(payloads) != 1 {
+		t.Errorf("expected the otlp log to be sent despite the json failure, got %d", len(payloads))
+	}
+}
+
+// otlpAttributes(payload) returns the attributes of the first log record of an OTLP export request by key
+func otlpAttributes(payload map[string]interface{}) map[string]map[string]interface{} {
+	resourceLogs := payload["resourceLogs"].([]interface{})[0].(map[string]interface{})
+	scopeLogs := resourceLogs["scopeLogs"].([]interface{})[0].(map[string]interface{})
+	record := scopeLogs["logRecords"].([]interface{})[0].(map[string]interface{})
+	attributes := map[string]map[string]interface{}{}
+	for _, kv := range record["attributes"].([]interface{}) {
+		kv := kv.(map[string]interface{})
+		attributes[kv["key"].(string)], _ = kv["value"].(map[string]interface{})
+	}
+	return attributes
+}
+
+func TestOTLPLimits(t *testing.T) {
+	collector, collectorURL := customslogloggertest.NewCaptureServer()
+	defer collector.Close()
+	var errs []error
+	newLogger := func(options *CustomHandlerOptions) *CustomLogger {
+		options.OTLPEndpoint = collectorURL + "/v1/logs"
+		options.InternalErrorHandler = func(err error) { errs = append(errs, err) }
+		return NewCustomLogger(io.Discard, options)
+	}
+
+	newLogger(&CustomHandlerOptions{JsonValidate: func(map[string]interface{}) error { return errors.New("rejected") }}).Info("invalid")
+	newLogger(&CustomHandlerOptions{JsonMaxValueLength: 4}).Info("long", "query", "select 1")
+	newLogger(&CustomHandlerOptions{MaxJsonBytes: 400}).Info("big", "payload", strings.Repeat("x", 400))
+	newLogger(&CustomHandlerOptions{MaxJsonBytes: 10}).Info("too big")
+
+	if len(errs) != 1 {
+		t.Errorf("expected the invalid log to be reported, got %v", errs)
+	}
+	payloads := collector.Payloads()
+	if len(payloads) != 2 {
+		t.Fatalf("expected the invalid and too big logs not to be exported, got %d otlp requests", len(payloads))
+	}
+	if attributes := otlpAttributes(payloads[0]); attributes["query"]["stringValue"] != "sele…" || attributes["query_truncated"]["boolValue"] != true {
+		t.Errorf("expected the value to be truncated, got %v", attributes)
+	}
+	if attributes := otlpAttributes(payloads[1]); len(attributes) != 1 || attributes[TRUNCATED_KEY]["boolValue"] != true {
+		t.Errorf("expected the attributes to be replaced by %s, got %v", TRUNCATED_KEY, attributes)
+	}
+}
+
+func TestOTLPValueKinds(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 30, 0, 500, time.UTC)
+	for _, test := range []struct {
+		value    slog.Value
+		expected map[string]interface{}
+	}{
+		{slog.Uint64Value(42), map[string]interface{}{"intValue": "42"}},
+		{slog.Uint64Value(math.MaxUint64), map[string]interface{}{"stringValue": "18446744073709551615"}},
+		{slog.DurationValue(1500 * time.Millisecond), map[string]interface{}{"intValue": "1500000000"}},
+		{slog.TimeValue(at), map[string]interface{}{"stringValue": "2024-05-01T12:30:00.0000005Z"}},
+		{slog.Float64Value(math.NaN()), map[string]interface{}{"stringValue": "NaN"}},
+	} {
+		if got := otlpValue(test.value); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("expected the OTLP value %v for %s, got %v", test.expected, test.value.Kind(), got)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !unix && !windows
+
+package customsloglogger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+)
+
+// syncUnsupported(err) checks if the error of a Sync() means the writer can't be synced
+func syncUnsupported(err error) bool {
+	return errors.Is(err, errors.ErrUnsupported)
+}
+
+// NewPlatformWriter(source) creates an io.Writer sending the text logs written on it to the logging
+// service of the platform : the local syslog daemon on Unix, the Event Log on Windows.
+// An error is returned on the other platforms
+func NewPlatformWriter(source string) (io.Writer, error) {
+	return nil, fmt.Errorf("no platform logging service on %s", runtime.GOOS)
+}
@@ -0,0 +1,47 @@
+//go:build unix
+
+package customsloglogger
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"syscall"
+)
+
+// platformSyslogPaths are the paths of the socket of the local syslog daemon, depending on the system
+// (Linux, macOS, BSD)
+var platformSyslogPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// platformFacility is the syslog facility of the logs written on the platform writer
+const platformFacility = "user"
+
+// platformPriority(level) returns the syslog priority of the logs of the level written on the platform writer :
+// the "user" facility (1) and the severity of the level,
+// i.e. 15 for Debug, 14 for Info, 12 for Warn, 11 for Error and 10 for Panic
+func platformPriority(level slog.Level) int {
+	return syslogFacilities[platformFacility]*8 + syslogSeverity(level)
+}
+
+// syncUnsupported(err) checks if the error of a Sync() means the writer can't be synced
+// (e.g. a terminal or a pipe, returning EINVAL or ENOTSUP)
+func syncUnsupported(err error) bool {
+	return errors.Is(err, syscall.EINVAL) || errors.Is(err, errors.ErrUnsupported)
+}
+
+// NewPlatformWriter(source) creates an io.Writer sending the text logs written on it to the logging
+// service of the platform, with the source as application name : on Unix, the local syslog daemon,
+// as RFC 5424 datagrams with the priority of the log level (see platformPriority()),
+// on Windows, the Event Log with the event type of the log level.
+// FormatCompact is the text format suited to the platform services (one line per log)
+func NewPlatformWriter(source string) (io.Writer, error) {
+	errs := []error{}
+	for _, path := range platformSyslogPaths {
+		w, err := newSyslogWriter("unixgram", path, syslogFacilities[platformFacility], source)
+		if err == nil {
+			return w, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
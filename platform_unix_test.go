@@ -0,0 +1,66 @@
+//go:build unix
+
+package customsloglogger
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestPlatformPriority(t *testing.T) {
+	for level, expected := range map[slog.Level]int{
+		slog.LevelDebug: 15,
+		slog.LevelInfo:  14,
+		slog.LevelWarn:  12,
+		slog.LevelError: 11,
+		LevelPanic:      10,
+	} {
+		if priority := platformPriority(level); priority != expected {
+			t.Errorf("expected the priority %d for %s, got %d", expected, levelName(level), priority)
+		}
+	}
+}
+
+func TestPlatformWriter(t *testing.T) {
+	//the socket path is kept short, under the limit of the unix socket addresses
+	dir, err := os.MkdirTemp("", "syslog")
+	if err != nil {
+		t.Fatalf("unable to create temp dir : %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log")
+	listener, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Fatalf("unable to listen : %s", err)
+	}
+	defer listener.Close()
+
+	previous := platformSyslogPaths
+	platformSyslogPaths = []string{filepath.Join(dir, "missing"), path}
+	defer func() { platformSyslogPaths = previous }()
+
+	w, err := NewPlatformWriter("billing")
+	if err != nil {
+		t.Fatalf("unexpected error : %s", err)
+	}
+	defer w.(io.Closer).Close()
+
+	NewCustomLogger(w, &CustomHandlerOptions{Format: FormatCompact, NoTime: true}).Warn("platform message", "id", 5)
+
+	listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+	packet := make([]byte, 2048)
+	n, _, err := listener.ReadFrom(packet)
+	if err != nil {
+		t.Fatalf("no syslog message received : %s", err)
+	}
+	frame := regexp.MustCompile(`^<12>1 \S+ \S+ billing \d+ - - WARN platform message id=5$`)
+	if !frame.Match(packet[:n]) {
+		t.Errorf("malformed platform message %q", packet[:n])
+	}
+}
@@ -0,0 +1,111 @@
+//go:build windows
+
+package customsloglogger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// Here are the event types of the Windows Event Log
+const (
+	EVENTLOG_ERROR_TYPE       = 0x0001
+	EVENTLOG_WARNING_TYPE     = 0x0002
+	EVENTLOG_INFORMATION_TYPE = 0x0004
+)
+
+// platformEventID is the event identifier of the logs written on the Event Log
+const platformEventID = 1
+
+var (
+	advapi32                  = syscall.NewLazyDLL("advapi32.dll")
+	procRegisterEventSourceW  = advapi32.NewProc("RegisterEventSourceW")
+	procDeregisterEventSource = advapi32.NewProc("DeregisterEventSource")
+	procReportEventW          = advapi32.NewProc("ReportEventW")
+)
+
+// platformEventType(level) returns the Event Log event type of the logs of the level written on the platform writer :
+// EVENTLOG_INFORMATION_TYPE for Debug and Info, EVENTLOG_WARNING_TYPE for Warn
+// and EVENTLOG_ERROR_TYPE for Error and above
+func platformEventType(level slog.Level) uint16 {
+	switch {
+	case level < slog.LevelWarn:
+		return EVENTLOG_INFORMATION_TYPE
+	case level < slog.LevelError:
+		return EVENTLOG_WARNING_TYPE
+	}
+	return EVENTLOG_ERROR_TYPE
+}
+
+// syncUnsupported(err) checks if the error of a Sync() means the writer can't be synced
+// (e.g. a console, returning EINVAL)
+func syncUnsupported(err error) bool {
+	return errors.Is(err, syscall.EINVAL) || errors.Is(err, errors.ErrUnsupported)
+}
+
+// eventLogWriter writes the text logs on the Windows Event Log
+type eventLogWriter struct {
+	mu     sync.Mutex
+	handle uintptr
+}
+
+// NewPlatformWriter(source) creates an io.Writer sending the text logs written on it to the logging
+// service of the platform, with the source as application name : on Unix, the local syslog daemon,
+// as RFC 5424 datagrams with the priority of the log level,
+// on Windows, the Event Log with the event type of the log level (see platformEventType()).
+// FormatCompact is the text format suited to the platform services (one line per log)
+func NewPlatformWriter(source string) (io.Writer, error) {
+	name, err := syscall.UTF16PtrFromString(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid event source %q : %w", source, err)
+	}
+	handle, _, err := procRegisterEventSourceW.Call(0, uintptr(unsafe.Pointer(name)))
+	if handle == 0 {
+		return nil, fmt.Errorf("unable to register event source %q : %w", source, err)
+	}
+	return &eventLogWriter{handle: handle}, nil
+}
+
+// Write() reports the text log with the information event type
+func (w *eventLogWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(slog.LevelInfo, p)
+}
+
+// WriteLevel() reports the text log with the event type of the level
+func (w *eventLogWriter) WriteLevel(level slog.Level, p []byte) (int, error) {
+	//the event strings are NUL terminated
+	msg, err := syscall.UTF16PtrFromString(strings.ReplaceAll(strings.TrimRight(string(p), "\r\n"), "\x00", " "))
+	if err != nil {
+		return 0, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.handle == 0 {
+		return 0, fmt.Errorf("event log writer is closed")
+	}
+	ok, _, err := procReportEventW.Call(w.handle, uintptr(platformEventType(level)), 0, platformEventID,
+		0, 1, 0, uintptr(unsafe.Pointer(&msg)), 0)
+	if ok == 0 {
+		return 0, fmt.Errorf("unable to report event : %w", err)
+	}
+	return len(p), nil
+}
+
+// Close() deregisters the event source
+func (w *eventLogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.handle == 0 {
+		return nil
+	}
+	procDeregisterEventSource.Call(w.handle)
+	w.handle = 0
+	return nil
+}
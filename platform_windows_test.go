@@ -0,0 +1,22 @@
+//go:build windows
+
+package customsloglogger
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestPlatformEventType(t *testing.T) {
+	for level, expected := range map[slog.Level]uint16{
+		slog.LevelDebug: EVENTLOG_INFORMATION_TYPE,
+		slog.LevelInfo:  EVENTLOG_INFORMATION_TYPE,
+		slog.LevelWarn:  EVENTLOG_WARNING_TYPE,
+		slog.LevelError: EVENTLOG_ERROR_TYPE,
+		LevelPanic:      EVENTLOG_ERROR_TYPE,
+	} {
+		if eventType := platformEventType(level); eventType != expected {
+			t.Errorf("expected the event type %d for %s, got %d", expected, levelName(level), eventType)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+//go:build race
+
+package customsloglogger
+
+// raceEnabled is true when the tests are run with the race detector, which makes extra allocations
+const raceEnabled = true
@@ -0,0 +1,127 @@
+package customsloglogger
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// REQUEST_KEY is the key of the group returned by RequestAttrs()
+const REQUEST_KEY = "request"
+
+// SensitiveQueryParams are the query parameters (case-insensitive) dropped
+// from the query string logged by RequestAttrs()
+var SensitiveQueryParams = []string{
+	"token", "access_token", "refresh_token", "id_token", "api_key", "apikey", "key",
+	"password", "passwd", "secret", "client_secret", "signature", "sig", "auth", "code",
+}
+
+// RequestAttrs(r) returns a REQUEST_KEY group with the method, the path, the remote address,
+// the user agent and the query string of the request, the SensitiveQueryParams being dropped :
+//
+//	logger.Info("handled", customsloglogger.RequestAttrs(r))
+func RequestAttrs(r *http.Request) slog.Attr {
+	attrs := []any{
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.String("remote_addr", r.RemoteAddr),
+		slog.String("user_agent", r.UserAgent()),
+	}
+	if query := sanitizedQuery(r.URL.RawQuery); query != "" {
+		attrs = append(attrs, slog.String("query", query))
+	}
+	return slog.Group(REQUEST_KEY, attrs...)
+}
+
+// sanitizedQuery(rawQuery) returns the query string without the SensitiveQueryParams
+func sanitizedQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		//a malformed query could hide a sensitive parameter
+		return ""
+	}
+	for param := range values {
+		for _, sensitive := range SensitiveQueryParams {
+			if strings.EqualFold(param, sensitive) {
+				values.Del(param)
+				break
+			}
+		}
+	}
+	return values.Encode()
+}
+
+// BodyAttr(key, r, limit) returns a key group with a snapshot of the limit first bytes of the body r
+// (e.g. of a request or a response) : their "content" as a string, or base64 encoded if they are binary
+// (with an "encoding" attribute), a "truncated" attribute if the body is longer, and the "error" of the reading if any.
+// As r is consumed, it returns a replacement reader yielding the whole body :
+//
+//	attr, body := customsloglogger.BodyAttr("body", r.Body, 1024)
+//	r.Body = io.NopCloser(body)
+//	logger.Debug("request received", customsloglogger.RequestAttrs(r), attr)
+func BodyAttr(key string, r io.Reader, limit int) (slog.Attr, io.Reader) {
+	limit = max(limit, 0)
+	//reading one more byte to know if the body is truncated
+	head := make([]byte, limit+1)
+	n, err := io.ReadFull(r, head)
+	head = head[:n]
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = nil
+	}
+
+	content := head[:min(n, limit)]
+	attrs := []any{}
+	if isBinary(content, n > limit) {
+		attrs = append(attrs, slog.String("content", base64.StdEncoding.EncodeToString(content)), slog.String("encoding", "base64"))
+	} else {
+		//not splitting the last character of a truncated body
+		attrs = append(attrs, slog.String("content", truncateUTF8(string(head), limit)))
+	}
+	if n > limit {
+		attrs = append(attrs, slog.Bool("truncated", true))
+	}
+
+	replacement := io.MultiReader(bytes.NewReader(head), r)
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+		replacement = io.MultiReader(bytes.NewReader(head), &errReader{err})
+	}
+	return slog.Group(key, attrs...), replacement
+}
+
+// isBinary(content, truncated) checks if the content isn't UTF-8 text or contains NUL bytes.
+// The last character of a truncated content may be incomplete
+func isBinary(content []byte, truncated bool) bool {
+	if bytes.IndexByte(content, 0) >= 0 {
+		return true
+	}
+	if truncated {
+		for i := len(content) - 1; i >= 0 && i >= len(content)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(content[i]) {
+				if !utf8.FullRune(content[i:]) {
+					content = content[:i]
+				}
+				break
+			}
+		}
+	}
+	return !utf8.Valid(content)
+}
+
+// errReader is an io.Reader returning the error of the reader it replaces
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
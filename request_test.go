@@ -0,0 +1,91 @@
+package customsloglogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestRequestAttrs(t *testing.T) {
+	r := httptest.NewRequest("GET", "/users/7?page=2&access_token=secret&Password=hunter2&sort=name", nil)
+	r.RemoteAddr = "10.0.0.7:51234"
+	r.Header.Set("User-Agent", "curl/8.0")
+
+	attr := RequestAttrs(r)
+	if attr.Key != REQUEST_KEY {
+		t.Fatalf("expected the %s group, got %s", REQUEST_KEY, attr.Key)
+	}
+	members := map[string]string{}
+	for _, member := range attr.Value.Group() {
+		members[member.Key] = member.Value.String()
+	}
+	for key, expected := range map[string]string{
+		"method":      "GET",
+		"path":        "/users/7",
+		"remote_addr": "10.0.0.7:51234",
+		"user_agent":  "curl/8.0",
+	} {
+		if members[key] != expected {
+			t.Errorf("expected %s = %q, got %q", key, expected, members[key])
+		}
+	}
+	query, err := url.ParseQuery(members["query"])
+	if err != nil {
+		t.Fatalf("invalid query %q : %s", members["query"], err)
+	}
+	if query.Get("page") != "2" || query.Get("sort") != "name" || query.Has("access_token") || query.Has("Password") {
+		t.Errorf("expected the sensitive parameters to be dropped, got %q", members["query"])
+	}
+
+	jsonBuf := &bytes.Buffer{}
+	NewCustomLogger(&bytes.Buffer{}, &CustomHandlerOptions{JsonWriter: jsonBuf}).Info("handled", RequestAttrs(httptest.NewRequest("POST", "/login", nil)))
+	var payload map[string]interface{}
+	if err := json.Unmarshal(jsonBuf.Bytes(), &payload); err != nil {
+		t.Fatalf("invalid json log : %s", err)
+	}
+	request, _ := payload[REQUEST_KEY].(map[string]interface{})
+	if request["method"] != "POST" || request["path"] != "/login" || request["query"] != nil {
+		t.Errorf("expected the request group nested in the json log, got %v", payload)
+	}
+}
+
+func TestBodyAttr(t *testing.T) {
+	body := strings.Repeat("héllo ", 10)
+	//the 9th byte is the first byte of the second "é"
+	attr, replacement := BodyAttr("body", strings.NewReader(body), 9)
+
+	expected := slog.Group("body", slog.String("content", "héllo h"), slog.Bool("truncated", true))
+	if !attr.Equal(expected) {
+		t.Errorf("expected %s, got %s", expected, attr)
+	}
+	if content, err := io.ReadAll(replacement); err != nil || string(content) != body {
+		t.Errorf("expected the replacement reader to yield the whole body, got %q (%v)", content, err)
+	}
+
+	binary := []byte{0x89, 'P', 'N', 'G', 0, 1, 2}
+	attr, replacement = BodyAttr("body", bytes.NewReader(binary), 16)
+	expected = slog.Group("body", slog.String("content", "iVBORwABAg=="), slog.String("encoding", "base64"))
+	if !attr.Equal(expected) {
+		t.Errorf("expected %s, got %s", expected, attr)
+	}
+	if content, _ := io.ReadAll(replacement); !bytes.Equal(content, binary) {
+		t.Errorf("expected the replacement reader to yield the whole binary body, got %v", content)
+	}
+
+	failure := errors.New("connection reset")
+	attr, replacement = BodyAttr("body", io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(failure)), 64)
+	expected = slog.Group("body", slog.String("content", "partial"), slog.String("error", "connection reset"))
+	if !attr.Equal(expected) {
+		t.Errorf("expected %s, got %s", expected, attr)
+	}
+	if content, err := io.ReadAll(replacement); string(content) != "partial" || !errors.Is(err, failure) {
+		t.Errorf("expected the replacement reader to yield the read bytes then the error, got %q (%v)", content, err)
+	}
+}
@@ -0,0 +1,275 @@
+package customsloglogger
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ROTATED_TIME_LAYOUT is the layout of the time in the names of the rotated log files
+const ROTATED_TIME_LAYOUT = "20060102T150405"
+
+// RotateOptions defines when the files of a RotatingWriter are rotated
+type RotateOptions struct {
+	//MaxSize is the maximum size in bytes of the log file : the file is rotated
+	//before a write which would exceed it. If 0, the file is not rotated on its size
+	MaxSize int64
+	//RotateInterval is the interval of the time rotation of the log file, the rotations being
+	//aligned on the local midnight (e.g. 24h rotates at midnight, 1h at each hour).
+	//The file is rotated at the boundary even if nothing is written. If 0, the file is not rotated on time
+	RotateInterval time.Duration
+	//Compress causes the rotated files to be gzip compressed (with a ".gz" suffix)
+	Compress bool
+	//Now is the clock of the rotations. If nil, time.Now is used
+	Now func() time.Time
+	//OnError is called with the errors of the rotations on time and of the compressions,
+	//which happen in the background (e.g. a full disk). If nil, they are reported on os.Stderr.
+	//The errors of the rotations on size are returned by Write()
+	OnError func(error)
+}
+
+// RotatingWriter is an io.Writer writing on a log file, rotated on its size or on time,
+// whichever comes first. The rotated files are named after the time their writing started :
+// "app.log" is rotated as "app-20240517T000000.log" (or "app-20240517T000000.log.gz")
+type RotatingWriter struct {
+	filename string
+	options  RotateOptions
+
+	mu           sync.Mutex
+	file         *os.File
+	size         int64
+	openedAt     time.Time
+	nextRotation time.Time
+	timer        *time.Timer
+	closed       bool
+
+	//compressions tracks the rotated files being compressed
+	compressions sync.WaitGroup
+}
+
+// NewRotatingWriter(filename, options) creates a RotatingWriter appending the logs to the file,
+// created if needed
+func NewRotatingWriter(filename string, options RotateOptions) (*RotatingWriter, error) {
+	if options.MaxSize < 0 || options.RotateInterval < 0 {
+		return nil, fmt.Errorf("invalid RotateOptions : MaxSize and RotateInterval must not be negative")
+	}
+	w := &RotatingWriter{filename: filename, options: options}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// reportError(err) reports an error of a background rotation or compression to the OnError option
+func (w *RotatingWriter) reportError(err error) {
+	if w.options.OnError != nil {
+		w.options.OnError(err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "customsloglogger : %s\n", err)
+}
+
+// now() returns the current time of the clock of the writer
+func (w *RotatingWriter) now() time.Time {
+	if w.options.Now == nil {
+		return time.Now()
+	}
+	return w.options.Now()
+}
+
+// open() opens the log file and schedules its time rotation
+func (w *RotatingWriter) open() error {
+	file, err := os.OpenFile(w.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("unable to open log file : %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("unable to open log file : %w", err)
+	}
+	w.file, w.size, w.openedAt = file, info.Size(), w.now()
+
+	if w.options.RotateInterval > 0 {
+		w.nextRotation = nextRotation(w.openedAt, w.options.RotateInterval)
+		w.schedule()
+	}
+	return nil
+}
+
+// nextRotation(now, interval) returns the first rotation boundary after now,
+// the boundaries being aligned on the local midnight
+func nextRotation(now time.Time, interval time.Duration) time.Time {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if interval%(24*time.Hour) == 0 {
+		//days are added to stay at midnight across the daylight saving time changes
+		return midnight.AddDate(0, 0, int(interval/(24*time.Hour)))
+	}
+	return midnight.Add((now.Sub(midnight)/interval + 1) * interval)
+}
+
+// schedule() arms the timer rotating the file at the next rotation boundary, even if nothing is written
+func (w *RotatingWriter) schedule() {
+	delay := w.nextRotation.Sub(w.now())
+	if w.timer == nil {
+		w.timer = time.AfterFunc(delay, w.rotateIfDue)
+	} else {
+		w.timer.Reset(delay)
+	}
+}
+
+// rotateIfDue() rotates the file if the rotation boundary is reached,
+// or schedules the timer again if it fired early. A rotation error is reported once the writer is unlocked
+func (w *RotatingWriter) rotateIfDue() {
+	w.mu.Lock()
+	if w.closed || w.file == nil {
+		w.mu.Unlock()
+		return
+	}
+	if w.now().Before(w.nextRotation) {
+		w.schedule()
+		w.mu.Unlock()
+		return
+	}
+	err := w.rotate()
+	w.mu.Unlock()
+	if err != nil {
+		w.reportError(err)
+	}
+}
+
+// Write() writes the log on the file, rotating it first if the rotation boundary is reached
+// or if the log would exceed the MaxSize. If the log file couldn't be opened again after a rotation,
+// it is opened again first, the error being returned while it fails
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	timeDue := w.options.RotateInterval > 0 && !w.now().Before(w.nextRotation)
+	sizeDue := w.options.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.options.MaxSize
+	if timeDue || sizeDue {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// rotate() renames the log file after the time its writing started, compresses it if needed
+// and opens a new log file. If it can't be opened, the file is nil until it is opened again by Write()
+func (w *RotatingWriter) rotate() error {
+	err := w.file.Close()
+	w.file = nil
+	if err != nil {
+		return fmt.Errorf("unable to close log file : %w", err)
+	}
+	rotated, err := w.rotatedName()
+	if err == nil {
+		err = os.Rename(w.filename, rotated)
+	}
+	if err != nil {
+		//the logs keep being written on the unrotated file
+		return errors.Join(fmt.Errorf("unable to rotate log file : %w", err), w.open())
+	}
+	if w.options.Compress {
+		w.compressions.Add(1)
+		go func() {
+			defer w.compressions.Done()
+			if err := compressFile(rotated); err != nil {
+				w.reportError(fmt.Errorf("unable to compress rotated log file : %w", err))
+			}
+		}()
+	}
+	return w.open()
+}
+
+// rotatedName() returns an unused name for the rotated log file
+func (w *RotatingWriter) rotatedName() (string, error) {
+	ext := filepath.Ext(w.filename)
+	base := strings.TrimSuffix(w.filename, ext) + "-" + w.openedAt.Format(ROTATED_TIME_LAYOUT)
+	for i := 0; i < 1000; i++ {
+		name := base + ext
+		if i > 0 {
+			name = fmt.Sprintf("%s.%d%s", base, i, ext)
+		}
+		if !fileExists(name) && !fileExists(name+".gz") {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("too many rotated files named %s", base+ext)
+}
+
+// fileExists(name) checks if the file exists
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return !errors.Is(err, os.ErrNotExist)
+}
+
+// compressFile(name) gzip compresses the file as name.gz and removes it
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	err = errors.Join(err, zw.Close(), dst.Close())
+	if err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+	return os.Remove(name)
+}
+
+// Sync() commits the log file to the storage
+func (w *RotatingWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed || w.file == nil {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+// Close() stops the time rotation, closes the log file and waits for the compression of the rotated files
+func (w *RotatingWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+	}
+	w.mu.Unlock()
+
+	w.compressions.Wait()
+	return err
+}
@@ -0,0 +1,198 @@
+package customsloglogger
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testClock is a clock whose time is set by the tests
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// readGzip returns the uncompressed content of a gzip file
+func readGzip(t *testing.T, name string) string {
+	t.Helper()
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("unable to open %s : %s", name, err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("invalid gzip file %s : %s", name, err)
+	}
+	content, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("invalid gzip file %s : %s", name, err)
+	}
+	return string(content)
+}
+
+func TestRotatingWriterSize(t *testing.T) {
+	dir := t.TempDir()
+	clock := &testClock{now: time.Date(2024, 5, 17, 10, 0, 0, 0, time.Local)}
+	w, err := NewRotatingWriter(filepath.Join(dir, "app.log"), RotateOptions{MaxSize: 10, Now: clock.Now})
+	if err != nil {
+		t.Fatalf("unexpected error : %s", err)
+	}
+	w.Write([]byte("first\n"))
+	w.Write([]byte("second\n"))
+	w.Write([]byte("third\n"))
+	w.Close()
+
+	for name, expected := range map[string]string{
+		"app.log":                   "third\n",
+		"app-20240517T100000.log":   "first\n",
+		"app-20240517T100000.1.log": "second\n",
+	} {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(content) != expected {
+			t.Errorf("expected %s to contain %q, got %q (%v)", name, expected, content, err)
+		}
+	}
+}
+
+func TestRotatingWriterInterval(t *testing.T) {
+	dir := t.TempDir()
+	clock := &testClock{now: time.Date(2024, 5, 17, 23, 0, 0, 0, time.Local)}
+	w, err := NewRotatingWriter(filepath.Join(dir, "app.log"), RotateOptions{
+		MaxSize:        1 << 20,
+		RotateInterval: 24 * time.Hour,
+		Compress:       true,
+		Now:            clock.Now,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error : %s", err)
+	}
+	w.Write([]byte("day 17\n"))
+
+	//the boundary is reached by a write
+	clock.Set(time.Date(2024, 5, 18, 0, 0, 1, 0, time.Local))
+	w.Write([]byte("day 18\n"))
+	w.Close()
+
+	if content := readGzip(t, filepath.Join(dir, "app-20240517T230000.log.gz")); content != "day 17\n" {
+		t.Errorf("expected the logs of the previous day in the compressed file, got %q", content)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "app.log")); string(content) != "day 18\n" {
+		t.Errorf("expected the logs of the day in the log file, got %q", content)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "app-20240517T230000.log")); err == nil {
+		t.Errorf("expected the uncompressed rotated file to be removed")
+	}
+}
+
+func TestRotatingWriterIdle(t *testing.T) {
+	dir := t.TempDir()
+	//the boundary is 50ms away
+	clock := &testClock{now: time.Date(2024, 5, 18, 0, 0, 0, 0, time.Local).Add(-50 * time.Millisecond)}
+	w, err := NewRotatingWriter(filepath.Join(dir, "app.log"), RotateOptions{RotateInterval: 24 * time.Hour, Now: clock.Now})
+	if err != nil {
+		t.Fatalf("unexpected error : %s", err)
+	}
+	defer w.Close()
+	w.Write([]byte("before midnight\n"))
+
+	//nothing is written anymore, the timer rotates the file at the boundary
+	clock.Set(time.Date(2024, 5, 18, 0, 0, 1, 0, time.Local))
+	rotated := filepath.Join(dir, "app-20240517T235959.log")
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if content, err := os.ReadFile(rotated); err == nil {
+			if string(content) != "before midnight\n" {
+				t.Errorf("unexpected rotated file content %q", content)
+			}
+			if content, _ := os.ReadFile(filepath.Join(dir, "app.log")); len(content) != 0 {
+				t.Errorf("expected a new empty log file, got %q", content)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	entries, _ := os.ReadDir(dir)
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	t.Errorf("expected the idle file to be rotated at the boundary, got %s", strings.Join(names, ", "))
+}
+
+func TestRotatingWriterReopenFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the directory of an open file can't be removed on windows")
+	}
+	dir := filepath.Join(t.TempDir(), "logs")
+	os.Mkdir(dir, 0o755)
+	clock := &testClock{now: time.Date(2024, 5, 18, 0, 0, 0, 0, time.Local).Add(-50 * time.Millisecond)}
+	errs := make(chan error, 1)
+	w, err := NewRotatingWriter(filepath.Join(dir, "app.log"), RotateOptions{
+		MaxSize:        10,
+		RotateInterval: 24 * time.Hour,
+		Now:            clock.Now,
+		OnError:        func(err error) { errs <- err },
+	})
+	if err != nil {
+		t.Fatalf("unexpected error : %s", err)
+	}
+	defer w.Close()
+	w.Write([]byte("first\n"))
+
+	//the timer rotation fails as the directory is removed, the error is reported
+	os.RemoveAll(dir)
+	clock.Set(time.Date(2024, 5, 18, 0, 0, 1, 0, time.Local))
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "unable to open log file") {
+			t.Errorf("unexpected rotation error %q", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the failed timer rotation to be reported")
+	}
+
+	//the writes fail while the log file can't be opened again, then it is opened again
+	if _, err := w.Write([]byte("lost\n")); err == nil {
+		t.Errorf("expected an error while the log file can't be opened")
+	}
+	os.Mkdir(dir, 0o755)
+	if _, err := w.Write([]byte("third\n")); err != nil {
+		t.Errorf("unexpected error once the directory is back : %s", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "app.log")); string(content) != "third\n" {
+		t.Errorf("expected the log file to be opened again, got %q", content)
+	}
+}
+
+func TestNextRotation(t *testing.T) {
+	now := time.Date(2024, 5, 17, 10, 20, 0, 0, time.Local)
+	for interval, expected := range map[time.Duration]time.Time{
+		24 * time.Hour:   time.Date(2024, 5, 18, 0, 0, 0, 0, time.Local),
+		48 * time.Hour:   time.Date(2024, 5, 19, 0, 0, 0, 0, time.Local),
+		time.Hour:        time.Date(2024, 5, 17, 11, 0, 0, 0, time.Local),
+		15 * time.Minute: time.Date(2024, 5, 17, 10, 30, 0, 0, time.Local),
+	} {
+		if next := nextRotation(now, interval); !next.Equal(expected) {
+			t.Errorf("expected the rotation of the %s interval at %s, got %s", interval, expected, next)
+		}
+	}
+}
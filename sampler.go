@@ -0,0 +1,112 @@
+package customsloglogger
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DEFAULT_SAMPLER_TICK is the default duration of the sampling windows
+const DEFAULT_SAMPLER_TICK = time.Second
+
+// samplerKey identifies the records counted together by a sampler
+type samplerKey struct {
+	level slog.Level
+	msg   string
+}
+
+// samplerCount is the count of records of a samplerKey within the current window
+type samplerCount struct {
+	end time.Time
+	n   int
+}
+
+// SamplerSettings are the sampling settings of a level (see SamplerLevels option) :
+// within each SamplerTick window, the Initial first logs with the same message are handled,
+// then only every Thereafter log (none if 0)
+type SamplerSettings struct {
+	Initial    int
+	Thereafter int
+}
+
+// enabled() checks if the settings sample the logs
+func (s SamplerSettings) enabled() bool {
+	return s.Initial > 0 || s.Thereafter > 0
+}
+
+// sampler limits the number of records logged with the same level and message
+// within a time window : the first records are logged, then only every Nth record
+type sampler struct {
+	tick     time.Duration
+	settings SamplerSettings
+	//levels overrides the settings for some levels
+	levels map[slog.Level]SamplerSettings
+
+	mu     sync.Mutex
+	counts map[samplerKey]*samplerCount
+}
+
+// newSampler(initial, thereafter, tick) creates a sampler logging the initial first records
+// of each level and message within a tick window, then every thereafter record.
+// If tick is not positive, DEFAULT_SAMPLER_TICK is used
+func newSampler(initial, thereafter int, tick time.Duration) *sampler {
+	if tick <= 0 {
+		tick = DEFAULT_SAMPLER_TICK
+	}
+	return &sampler{
+		tick:     tick,
+		settings: SamplerSettings{Initial: initial, Thereafter: thereafter},
+		counts:   make(map[samplerKey]*samplerCount),
+	}
+}
+
+// newOptionsSampler(options) creates the sampler defined by the options,
+// or returns nil if sampling is disabled
+func newOptionsSampler(options *CustomHandlerOptions) *sampler {
+	settings := SamplerSettings{Initial: options.SamplerInitial, Thereafter: options.SamplerThereafter}
+	enabled := settings.enabled()
+	for _, levelSettings := range options.SamplerLevels {
+		enabled = enabled || levelSettings.enabled()
+	}
+	if !enabled {
+		return nil
+	}
+	s := newSampler(settings.Initial, settings.Thereafter, options.SamplerTick)
+	s.levels = options.SamplerLevels
+	return s
+}
+
+// sample(level, msg, now) counts the record and checks if it must be logged
+func (s *sampler) sample(level slog.Level, msg string, now time.Time) bool {
+	settings, ok := s.levels[level]
+	if !ok {
+		settings = s.settings
+	}
+	if !settings.enabled() {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := samplerKey{level, msg}
+	count, ok := s.counts[key]
+	if !ok || now.After(count.end) {
+		//removing the ended windows to avoid growing forever with distinct messages
+		if len(s.counts) >= 1024 {
+			for k, c := range s.counts {
+				if now.After(c.end) {
+					delete(s.counts, k)
+				}
+			}
+		}
+		count = &samplerCount{end: now.Add(s.tick)}
+		s.counts[key] = count
+	}
+	count.n++
+
+	if count.n <= settings.Initial {
+		return true
+	}
+	return settings.Thereafter > 0 && (count.n-settings.Initial)%settings.Thereafter == 0
+}
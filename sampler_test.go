@@ -0,0 +1,117 @@
+package customsloglogger
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSampler(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{
+		Format:            FormatCompact,
+		SamplerInitial:    5,
+		SamplerThereafter: 10,
+		SamplerTick:       time.Minute,
+	})
+
+	for i := 0; i < 100; i++ {
+		logger.Info("hot path")
+	}
+	logger.Info("other message")
+	logger.Warn("hot path")
+
+	//5 first logs, then the 15th, 25th, ..., 95th
+	if count := strings.Count(buf.String(), "INFO") - 1; count != 14 {
+		t.Errorf("expected 14 sampled logs, got %d", count)
+	}
+	if !strings.Contains(buf.String(), "other message") || !strings.Contains(buf.String(), "WARN") {
+		t.Errorf("expected other messages and levels to be sampled separately :\n%s", buf.String())
+	}
+}
+
+func TestSamplerWindow(t *testing.T) {
+	s := newSampler(1, 0, time.Second)
+	now := time.Now()
+
+	if !s.sample(0, "msg", now) || s.sample(0, "msg", now.Add(500*time.Millisecond)) {
+		t.Errorf("expected only the first record of the window to be sampled")
+	}
+	if !s.sample(0, "msg", now.Add(2*time.Second)) {
+		t.Errorf("expected the first record of a new window to be sampled")
+	}
+}
+
+func TestWithSampling(t *testing.T) {
+	buf := &bytes.Buffer{}
+	parent := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, SamplerTick: time.Minute})
+	child := parent.WithSampling(2, 10)
+
+	for i := 0; i < 50; i++ {
+		child.Info("hot path")
+		parent.Info("hot path")
+	}
+	parent.With("id", 1).Info("derived")
+
+	//the child logs the 2 first records, then the 12th, 22nd, 32nd and 42nd ones
+	if count := strings.Count(buf.String(), "hot path\n"); count != 50+6 {
+		t.Errorf("expected the 50 parent logs and 6 sampled child logs, got %d", count)
+	}
+	if !strings.Contains(buf.String(), "derived id=1") {
+		t.Errorf("expected the loggers derived from the parent not to be sampled")
+	}
+	if parent.Handler().sampler != nil || parent.Handler().Options.SamplerInitial != 0 {
+		t.Errorf("expected the sampling not to leak into the parent")
+	}
+}
+
+func TestSamplerLevels(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{
+		Format:            FormatCompact,
+		MinimumLevel:      slog.LevelDebug,
+		SamplerInitial:    2,
+		SamplerThereafter: 0,
+		SamplerTick:       time.Minute,
+		SamplerLevels: map[slog.Level]SamplerSettings{
+			slog.LevelDebug: {Initial: 1, Thereafter: 5},
+			slog.LevelError: {},
+		},
+	})
+
+	for i := 0; i < 20; i++ {
+		logger.Debug("hot path")
+		logger.Info("hot path")
+		logger.Error("hot path")
+	}
+
+	//the debug logs 1, 6, 11 and 16, the 2 first info logs and all the error logs
+	for level, expected := range map[string]int{"DEBUG": 4, "INFO": 2, "ERROR": 20} {
+		if count := strings.Count(buf.String(), level); count != expected {
+			t.Errorf("expected %d sampled %s logs, got %d", expected, level, count)
+		}
+	}
+}
+
+func TestSamplerClock(t *testing.T) {
+	buf := &bytes.Buffer{}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{
+		Format:         FormatCompact,
+		SamplerInitial: 1,
+		SamplerTick:    time.Minute,
+		Now:            func() time.Time { return now },
+	})
+
+	logger.Info("hot path")
+	logger.Info("hot path")
+	//the sampling windows follow the clock of the handler
+	now = now.Add(2 * time.Minute)
+	logger.Info("hot path")
+
+	if count := strings.Count(buf.String(), "hot path"); count != 2 {
+		t.Errorf("expected the first log of each window of the handler clock, got %d :\n%s", count, buf.String())
+	}
+}
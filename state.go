@@ -0,0 +1,74 @@
+package customsloglogger
+
+import (
+	"log/slog"
+	"slices"
+)
+
+// HandlerState is a read-only snapshot of the state of a handler (see CustomHandler.State()),
+// e.g. to debug a chain of With() and WithGroup() calls
+type HandlerState struct {
+	//Groups is the group path of the handler (see WithGroup())
+	Groups []string
+	//Component is the component of the handler (see Named())
+	Component string
+	//Attrs are the additionnal attributes of the handler (see With()),
+	//with the dotted prefix of the group they belong to
+	Attrs []slog.Attr
+	//CtxKeys are the keys of the context attributes of the handler (see WithCtxAttrsKeys())
+	CtxKeys []string
+	//Options is a copy of the options of the handler, the unset options
+	//having their default value (e.g. JsonTimeout, BannerWidth)
+	Options CustomHandlerOptions
+}
+
+// State() returns a snapshot of the groups, attributes, context keys and options of the handler.
+// Modifying the snapshot doesn't modify the handler
+func (m *CustomHandler) State() HandlerState {
+	state := HandlerState{
+		Groups:    slices.Clone(m.groups()),
+		Component: m.Component,
+		Attrs:     make([]slog.Attr, 0, len(m.AdditionnalAttrs)),
+		CtxKeys:   make([]string, 0, len(m.CtxAttrsKeys)),
+		Options:   *m.Options.resolved(),
+	}
+	for _, parent := range m.parentAttrs {
+		prefix := groupPrefix(parent.groups)
+		for _, attr := range parent.attrs {
+			state.Attrs = append(state.Attrs, slog.Attr{Key: prefix + attr.Key, Value: attr.Value})
+		}
+	}
+	prefix := groupPrefix(state.Groups)
+	for _, attr := range m.AdditionnalAttrs {
+		state.Attrs = append(state.Attrs, slog.Attr{Key: prefix + attr.Key, Value: attr.Value})
+	}
+	for _, key := range m.CtxAttrsKeys {
+		state.CtxKeys = append(state.CtxKeys, string(key))
+	}
+	return state
+}
+
+// resolved() returns a copy of the options, the unset options having their default value
+func (o *CustomHandlerOptions) resolved() *CustomHandlerOptions {
+	options := o.clone()
+	if method, err := o.jsonMethod(); err == nil {
+		options.JsonMethod = method
+	}
+	options.JsonTimeout = o.jsonTimeout()
+	options.JsonGzipMinSize = o.jsonGzipMinSize()
+	options.BannerWidth = o.bannerWidth()
+	options.TimeLayout = o.timeLayout()
+	options.AttrPrefix = o.attrPrefix()
+	options.AttrSeparator = o.attrSeparator()
+	options.CorrelationIDKey = o.correlationIDKey()
+	options.SourceURLTemplate = o.sourceURLTemplate()
+	if options.InternalErrorInterval <= 0 {
+		options.InternalErrorInterval = DEFAULT_INTERNAL_ERROR_INTERVAL
+	}
+	if options.ErrorKeys == nil {
+		options.ErrorKeys = slices.Clone(DEFAULT_ERROR_KEYS)
+	}
+	options.TextMinimumLevel = o.textLevel()
+	options.JsonMinimumLevel = o.jsonLevel()
+	return options
+}
@@ -0,0 +1,49 @@
+package customsloglogger
+
+import (
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHandlerState(t *testing.T) {
+	root := NewCustomLogger(io.Discard, &CustomHandlerOptions{MinimumLevel: slog.LevelDebug, BannerWidth: 50})
+	logger := root.Named("db").With("app", "api").WithGroup("req").With("url", "/users").
+		WithGroup("auth").With("user", "bob").WithCtxAttrsKeys([]string{"request_id"})
+
+	state := logger.Handler().State()
+	if !reflect.DeepEqual(state.Groups, []string{"req", "auth"}) {
+		t.Errorf("expected the group path req.auth, got %v", state.Groups)
+	}
+	if state.Component != "db" {
+		t.Errorf("expected the component db, got %q", state.Component)
+	}
+	keys := []string{}
+	for _, attr := range state.Attrs {
+		keys = append(keys, attr.Key)
+	}
+	if !reflect.DeepEqual(keys, []string{"app", "req.url", "req.auth.user"}) {
+		t.Errorf("expected the attributes with their group prefix, got %v", state.Attrs)
+	}
+	if !reflect.DeepEqual(state.CtxKeys, []string{"request_id"}) {
+		t.Errorf("expected the context keys, got %v", state.CtxKeys)
+	}
+	if state.Options.BannerWidth != 50 || state.Options.JsonTimeout != DEFAULT_JSON_TIMEOUT || state.Options.JsonMethod != "POST" {
+		t.Errorf("expected the resolved options, got %+v", state.Options)
+	}
+	if state.Options.TextMinimumLevel.Level() != slog.LevelDebug {
+		t.Errorf("expected the resolved text level, got %v", state.Options.TextMinimumLevel)
+	}
+
+	//the snapshot is read-only
+	state.Options.JsonTimeout = time.Minute
+	state.Attrs[0] = slog.String("app", "changed")
+	if again := logger.Handler().State(); again.Options.JsonTimeout != DEFAULT_JSON_TIMEOUT || again.Attrs[0].Value.String() != "api" {
+		t.Errorf("expected the handler not to be modified by its snapshot, got %+v", again)
+	}
+	if parent := root.Handler().State(); len(parent.Groups) != 0 || len(parent.Attrs) != 0 {
+		t.Errorf("expected the root handler to keep its state, got %+v", parent)
+	}
+}
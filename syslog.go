@@ -0,0 +1,164 @@
+package customsloglogger
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// levelWriter is implemented by the writers (like the syslog writer) needing the level of the text logs
+// written on them : the handler calls WriteLevel() instead of Write()
+type levelWriter interface {
+	WriteLevel(level slog.Level, p []byte) (int, error)
+}
+
+// syslogFacilities are the codes of the syslog facilities
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogSeverity(level) returns the syslog severity of a log level :
+// 7 (debug) for Debug, 6 (informational) for Info, 4 (warning) for Warn and 3 (error) for Error
+func syslogSeverity(level slog.Level) int {
+	switch {
+	case level < slog.LevelInfo:
+		return 7
+	case level < slog.LevelWarn:
+		return 6
+	case level < slog.LevelError:
+		return 4
+	case level < LevelPanic:
+		return 3
+	}
+	return 2
+}
+
+// syslogWriter writes the text logs as RFC 5424 syslog messages
+type syslogWriter struct {
+	network  string
+	addr     string
+	facility int
+	hostname string
+	appName  string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogWriter() creates an io.Writer sending the text logs written on it to the syslog server
+// at addr (e.g. "localhost:514") over network ("udp" or "tcp"), as RFC 5424 messages
+// of the facility (e.g. "user", "daemon", "local0") with the severity of the log level.
+// Over tcp, the messages are octet-counting framed and the writer reconnects after a connection drop.
+// FormatCompact is the text format suited to syslog (one line per log).
+// The writer implements io.Closer to close the connection
+func NewSyslogWriter(network, addr, facility string) (io.Writer, error) {
+	code, ok := syslogFacilities[facility]
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility %q", facility)
+	}
+	return newSyslogWriter(network, addr, code, filepath.Base(os.Args[0]))
+}
+
+// newSyslogWriter(network, addr, facility, appName) creates a syslog writer connected to the server at addr,
+// sending the messages of the facility code with the application name
+func newSyslogWriter(network, addr string, facility int, appName string) (*syslogWriter, error) {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	w := &syslogWriter{
+		network:  network,
+		addr:     addr,
+		facility: facility,
+		hostname: hostname,
+		appName:  appName,
+	}
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// connect() (re)connects the writer to the syslog server
+func (w *syslogWriter) connect() error {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+	conn, err := net.Dial(w.network, w.addr)
+	if err != nil {
+		return fmt.Errorf("unable to connect to syslog server : %w", err)
+	}
+	w.conn = conn
+	return nil
+}
+
+// Write() sends the text log with the Info severity
+func (w *syslogWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(slog.LevelInfo, p)
+}
+
+// WriteLevel() sends the text log with the severity of the level.
+// Over a stream network (tcp), the message is sent again on a new connection if the connection was dropped
+func (w *syslogWriter) WriteLevel(level slog.Level, p []byte) (int, error) {
+	msg := w.message(level, p)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		if err := w.connect(); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := w.conn.Write(msg); err != nil {
+		if w.datagram() {
+			return 0, err
+		}
+		if err := w.connect(); err != nil {
+			return 0, err
+		}
+		if _, err := w.conn.Write(msg); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// message(level, p) returns the RFC 5424 message of the text log :
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID - - MSG, prefixed by its length over a stream network (tcp)
+func (w *syslogWriter) message(level slog.Level, p []byte) []byte {
+	msg := fmt.Appendf(nil, "<%d>1 %s %s %s %d - - ", w.facility*8+syslogSeverity(level),
+		time.Now().Format(time.RFC3339Nano), w.hostname, w.appName, os.Getpid())
+	msg = append(msg, bytes.TrimRight(p, "\n")...)
+	if w.datagram() {
+		return msg
+	}
+	return fmt.Appendf(nil, "%d %s", len(msg), msg)
+}
+
+// datagram() returns true if the network sends each message in its own datagram (udp, unixgram)
+func (w *syslogWriter) datagram() bool {
+	return strings.HasPrefix(w.network, "udp") || w.network == "unixgram"
+}
+
+// Close() closes the connection to the syslog server
+func (w *syslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
@@ -0,0 +1,117 @@
+package customsloglogger
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogWriter(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen : %s", err)
+	}
+	defer listener.Close()
+
+	w, err := NewSyslogWriter("udp", listener.LocalAddr().String(), "local0")
+	if err != nil {
+		t.Fatalf("unexpected error : %s", err)
+	}
+	defer w.(io.Closer).Close()
+
+	logger := NewCustomLogger(w, &CustomHandlerOptions{Format: FormatCompact, MinimumLevel: slog.LevelDebug})
+	frame := regexp.MustCompile(`^<(\d+)>1 \S+ \S+ \S+ \d+ - - (.*)$`)
+	for _, test := range []struct {
+		log      func(msg string, args ...any)
+		priority int
+		level    string
+	}{
+		{logger.Debug, 16*8 + 7, "DEBUG"},
+		{logger.Info, 16*8 + 6, "INFO"},
+		{logger.Warn, 16*8 + 4, "WARN"},
+		{logger.Error, 16*8 + 3, "ERROR"},
+	} {
+		test.log("syslog message", "id", 5)
+
+		listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+		packet := make([]byte, 2048)
+		n, _, err := listener.ReadFrom(packet)
+		if err != nil {
+			t.Fatalf("no syslog message received : %s", err)
+		}
+		match := frame.FindStringSubmatch(string(packet[:n]))
+		if match == nil {
+			t.Fatalf("malformed syslog message %q", packet[:n])
+		}
+		if priority, _ := strconv.Atoi(match[1]); priority != test.priority {
+			t.Errorf("expected the priority %d for %s, got %d", test.priority, test.level, priority)
+		}
+		if !strings.HasPrefix(match[2], test.level+" ") || !strings.HasSuffix(match[2], "syslog message id=5") {
+			t.Errorf("unexpected syslog message %q", match[2])
+		}
+	}
+}
+
+func TestSyslogWriterTCPReconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen : %s", err)
+	}
+	defer listener.Close()
+
+	frames := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			//reading one octet-counting framed message per connection, then dropping it
+			reader := bufio.NewReader(conn)
+			length, err := reader.ReadString(' ')
+			if err == nil {
+				n, _ := strconv.Atoi(strings.TrimSpace(length))
+				msg := make([]byte, n)
+				if _, err := io.ReadFull(reader, msg); err == nil {
+					frames <- string(msg)
+				}
+			}
+			conn.Close()
+		}
+	}()
+
+	w, err := NewSyslogWriter("tcp", listener.Addr().String(), "user")
+	if err != nil {
+		t.Fatalf("unexpected error : %s", err)
+	}
+	defer w.(io.Closer).Close()
+
+	w.Write([]byte("first\n"))
+	if frame := <-frames; !strings.HasPrefix(frame, "<14>1 ") || !strings.HasSuffix(frame, " - - first") {
+		t.Errorf("unexpected first frame %q", frame)
+	}
+
+	//the writes on the dropped connection fail once the drop is detected, then the writer reconnects
+	deadline := time.After(5 * time.Second)
+	for {
+		if _, err := w.Write([]byte("after drop\n")); err != nil {
+			t.Fatalf("unexpected error : %s", err)
+		}
+		select {
+		case frame := <-frames:
+			if !strings.HasSuffix(frame, " - - after drop") {
+				t.Errorf("unexpected frame after reconnection %q", frame)
+			}
+			return
+		case <-deadline:
+			t.Fatalf("no message received after the connection drop")
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package customsloglogger
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DEFAULT_JSON_MAX_IDLE_CONNS_PER_HOST is the number of idle connections kept open
+// to the json log service by the default transport (see DefaultJSONTransport())
+const DEFAULT_JSON_MAX_IDLE_CONNS_PER_HOST = 16
+
+// DEFAULT_JSON_IDLE_CONN_TIMEOUT is the duration an idle connection to the json log service
+// is kept open by the default transport (see DefaultJSONTransport())
+const DEFAULT_JSON_IDLE_CONN_TIMEOUT = 90 * time.Second
+
+// DefaultJSONTransport() returns a new *http.Transport tuned to send the json logs : it keeps
+// DEFAULT_JSON_MAX_IDLE_CONNS_PER_HOST connections alive to the log service (instead of the 2
+// of the http.DefaultTransport) and attempts HTTP/2. It is the transport of the default JsonClient,
+// and can be cloned and tweaked for a custom JsonClient :
+//
+//	transport := customsloglogger.DefaultJSONTransport()
+//	transport.Proxy = nil
+//	options.JsonClient = &http.Client{Transport: transport}
+func DefaultJSONTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = DEFAULT_JSON_MAX_IDLE_CONNS_PER_HOST
+	transport.IdleConnTimeout = DEFAULT_JSON_IDLE_CONN_TIMEOUT
+	transport.ForceAttemptHTTP2 = true
+	return transport
+}
+
+// defaultJSONClient is the http.Client shared by the handlers without JsonClient option,
+// so that they reuse the connections to the json log service
+var defaultJSONClient = sync.OnceValue(func() *http.Client {
+	return &http.Client{Transport: DefaultJSONTransport()}
+})
+
+// jsonClient() returns the JsonClient option or the default http.Client if it isn't defined
+func (o *CustomHandlerOptions) jsonClient() *http.Client {
+	if o.JsonClient == nil {
+		return defaultJSONClient()
+	}
+	return o.JsonClient
+}
+
+// WithJSONTLS() sets a JsonClient authenticating with the client certificate cert
+// to send the json logs to a JsonLogURL requiring mutual TLS, and verifying the server
+// certificate with caPool (the system pool if nil).
+// An error is returned if the certificate is incomplete, invalid or expired
+func (o *CustomHandlerOptions) WithJSONTLS(cert tls.Certificate, caPool *x509.CertPool) error {
+	if len(cert.Certificate) == 0 || cert.PrivateKey == nil {
+		return fmt.Errorf("client certificate must contain a certificate and a private key")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("invalid client certificate : %w", err)
+	}
+	if now := time.Now(); now.Before(leaf.NotBefore) || now.After(leaf.NotAfter) {
+		return fmt.Errorf("client certificate is not valid at %s (valid from %s to %s)",
+			now.Format(time.DateTime), leaf.NotBefore.Format(time.DateTime), leaf.NotAfter.Format(time.DateTime))
+	}
+
+	transport := DefaultJSONTransport()
+	transport.TLSClientConfig = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      caPool,
+		MinVersion:   tls.VersionTLS12,
+	}
+	o.JsonClient = &http.Client{Transport: transport}
+	return nil
+}
@@ -0,0 +1,136 @@
+package customsloglogger
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newClientCertificate returns a self-signed client certificate valid from notBefore to notAfter
+func newClientCertificate(t *testing.T, notBefore, notAfter time.Time) (tls.Certificate, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate key : %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "logger"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unable to create certificate : %s", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("unable to parse certificate : %s", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, leaf
+}
+
+func TestWithJSONTLS(t *testing.T) {
+	cert, leaf := newClientCertificate(t, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+
+	received := make(chan string, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- string(body)
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(leaf)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	serverCAs := x509.NewCertPool()
+	serverCAs.AddCert(server.Certificate())
+
+	options := &CustomHandlerOptions{JsonLogURL: server.URL}
+	if err := options.WithJSONTLS(cert, serverCAs); err != nil {
+		t.Fatalf("unexpected error : %s", err)
+	}
+	logger := NewCustomLogger(io.Discard, options)
+	logger.Info("mutual tls")
+
+	select {
+	case body := <-received:
+		if body == "" {
+			t.Errorf("expected the json log to be delivered")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the json log to be delivered over mutual tls")
+	}
+	if stats := logger.Stats(); stats.FailedCount != 0 {
+		t.Errorf("expected no failed delivery, got %+v", stats)
+	}
+}
+
+func TestWithJSONTLSInvalidCertificate(t *testing.T) {
+	expired, _ := newClientCertificate(t, time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour))
+
+	for name, cert := range map[string]tls.Certificate{
+		"empty":   {},
+		"no key":  {Certificate: expired.Certificate},
+		"invalid": {Certificate: [][]byte{[]byte("not a certificate")}, PrivateKey: expired.PrivateKey},
+		"expired": expired,
+	} {
+		options := &CustomHandlerOptions{}
+		if err := options.WithJSONTLS(cert, nil); err == nil {
+			t.Errorf("%s : expected an error", name)
+		}
+		if options.JsonClient != nil {
+			t.Errorf("%s : expected no client to be set", name)
+		}
+	}
+}
+
+func TestDefaultJSONTransport(t *testing.T) {
+	transport := DefaultJSONTransport()
+	if transport.MaxIdleConnsPerHost != DEFAULT_JSON_MAX_IDLE_CONNS_PER_HOST || transport.IdleConnTimeout != DEFAULT_JSON_IDLE_CONN_TIMEOUT || !transport.ForceAttemptHTTP2 {
+		t.Errorf("unexpected default transport tuning %d %s %t", transport.MaxIdleConnsPerHost, transport.IdleConnTimeout, transport.ForceAttemptHTTP2)
+	}
+	if transport == DefaultJSONTransport() {
+		t.Errorf("expected a new transport for each call")
+	}
+
+	var connections, requests atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		requests.Add(1)
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	logger := NewCustomLogger(io.Discard, &CustomHandlerOptions{JsonLogURL: server.URL, SyncJson: true})
+	for i := 0; i < 5; i++ {
+		logger.Info("sequential delivery", "i", i)
+	}
+	if requests.Load() != 5 {
+		t.Fatalf("expected 5 json logs delivered, got %d", requests.Load())
+	}
+	if connections.Load() != 1 {
+		t.Errorf("expected the connection to be reused, got %d connections", connections.Load())
+	}
+}
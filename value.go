@@ -0,0 +1,157 @@
+package customsloglogger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// textValue(v) returns the string representation of an attribute value in text logs.
+// Errors implementing fmt.Formatter (e.g. errors with a stack) are rendered with %+v,
+// times are rendered with the TimeLayout option (without monotonic clock reading).
+// Invalid UTF-8 sequences (e.g. of raw protocol frames) are replaced by the Unicode replacement character
+func (m *CustomHandler) textValue(v slog.Value) string {
+	if err, ok := errorValue(v); ok {
+		if _, ok := err.(fmt.Formatter); ok {
+			return validUTF8(fmt.Sprintf("%+v", err))
+		}
+		return validUTF8(err.Error())
+	}
+	if v.Kind() == slog.KindTime {
+		return v.Time().Format(m.Options.timeLayout())
+	}
+	return validUTF8(v.String())
+}
+
+// validUTF8(s) returns s with its invalid UTF-8 sequences replaced by the Unicode replacement character
+func validUTF8(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	return strings.ToValidUTF8(s, "\uFFFD")
+}
+
+// truncateUTF8(s, n) returns the first n bytes of s at most, without splitting a character
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// jsonValue(v) returns the representation of an attribute value in json (and MessagePack) logs.
+// Booleans and numbers are kept typed (the non finite floats, which json can't represent, by their string).
+// Groups are represented by a nested map of their attributes.
+// Errors are represented by the array of the messages of their chain
+// (the error itself, then the errors it wraps).
+// Times are represented by their RFC 3339 string, durations by their string
+// or their number of nanoseconds with the JsonDurationNanos option.
+// Slices, arrays, maps and structs are represented natively by their marshalled json.RawMessage
+// (e.g. [1,2,3], a []byte by its base64 string), or by their string if they can't be marshalled.
+// json.Number values are represented by their number.
+// Invalid UTF-8 sequences are replaced by the Unicode replacement character, so that a strict
+// JsonMarshal doesn't fail on them
+func (m *CustomHandler) jsonValue(v slog.Value) interface{} {
+	if err, ok := errorValue(v); ok {
+		return errorChain(err)
+	}
+	switch v.Kind() {
+	case slog.KindBool:
+		return v.Bool()
+	case slog.KindInt64:
+		return v.Int64()
+	case slog.KindUint64:
+		return v.Uint64()
+	case slog.KindFloat64:
+		if f := v.Float64(); !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return f
+		}
+	case slog.KindGroup:
+		group := make(map[string]interface{})
+		m.addJsonGroup(group, v.Group())
+		return group
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	case slog.KindDuration:
+		if m.Options.JsonDurationNanos {
+			return v.Duration().Nanoseconds()
+		}
+	case slog.KindAny:
+		if number, ok := v.Any().(json.Number); ok {
+			//e.g. the metric values of EMFAttrs()
+			return number
+		}
+		//the composite values are marshalled once, their json being embedded in the json log as is
+		if isComposite(v.Any()) {
+			if jsonByte, err := m.Options.jsonMarshal()(v.Any()); err == nil {
+				return json.RawMessage(jsonByte)
+			}
+		}
+	}
+	return validUTF8(v.String())
+}
+
+// isComposite(value) checks if the value is a slice, an array, a map or a struct (or a pointer to one of them)
+func isComposite(value any) bool {
+	t := reflect.TypeOf(value)
+	if t == nil {
+		return false
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+		return true
+	}
+	return false
+}
+
+// addJsonGroup(group, attrs) adds the attributes of a group in its json map.
+// The members of the nested groups without key are inlined
+func (m *CustomHandler) addJsonGroup(group map[string]interface{}, attrs []slog.Attr) {
+	for _, a := range attrs {
+		if a.Value.Kind() == slog.KindGroup && a.Key == "" {
+			m.addJsonGroup(group, a.Value.Group())
+			continue
+		}
+		group[a.Key] = m.jsonValue(a.Value)
+	}
+}
+
+// errorValue(v) returns the error of a KindAny value holding an error
+func errorValue(v slog.Value) (error, bool) {
+	if v.Kind() != slog.KindAny {
+		return nil, false
+	}
+	err, ok := v.Any().(error)
+	return err, ok && err != nil
+}
+
+// errorChain(err) returns the messages of the error and of all the errors it wraps,
+// depth-first for errors wrapping several errors (e.g. errors.Join())
+func errorChain(err error) []string {
+	chain := []string{validUTF8(err.Error())}
+	switch wrapper := err.(type) {
+	case interface{ Unwrap() []error }:
+		for _, wrapped := range wrapper.Unwrap() {
+			if wrapped != nil {
+				chain = append(chain, errorChain(wrapped)...)
+			}
+		}
+	default:
+		if wrapped := errors.Unwrap(err); wrapped != nil {
+			chain = append(chain, errorChain(wrapped)...)
+		}
+	}
+	return chain
+}
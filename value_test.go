@@ -0,0 +1,230 @@
+package customsloglogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darthyoh/custom-slog-logger/customslogloggertest"
+)
+
+func TestErrorChain(t *testing.T) {
+	server, serverURL := customslogloggertest.NewCaptureServer()
+	defer server.Close()
+	buf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{JsonLogURL: serverURL, Format: FormatCompact})
+
+	inner := errors.New("connection refused")
+	outer := fmt.Errorf("outer: %w", inner)
+	logger.Error("request failed", "err", outer, "joined", errors.Join(errors.New("first"), errors.New("second")))
+
+	if !strings.Contains(buf.String(), `err="outer: connection refused"`) {
+		t.Errorf("text output doesn't contain the error messages : %q", buf.String())
+	}
+
+	payloads := server.Payloads()
+	if len(payloads) != 1 {
+		t.Fatalf("expected 1 json payload, got %d", len(payloads))
+	}
+	expected := []any{"outer: connection refused", "connection refused"}
+	if !reflect.DeepEqual(payloads[0]["err"], expected) {
+		t.Errorf("unexpected error chain %v, expected %v", payloads[0]["err"], expected)
+	}
+	expected = []any{"first\nsecond", "first", "second"}
+	if !reflect.DeepEqual(payloads[0]["joined"], expected) {
+		t.Errorf("unexpected joined error chain %v, expected %v", payloads[0]["joined"], expected)
+	}
+}
+
+// stackError is an error implementing fmt.Formatter to print a stack with %+v
+type stackError struct{}
+
+func (stackError) Error() string { return "stack error" }
+
+func (e stackError) Format(s fmt.State, verb rune) {
+	fmt.Fprint(s, e.Error())
+	if s.Flag('+') {
+		fmt.Fprint(s, "\n\tmain.go:12")
+	}
+}
+
+func TestErrorFormatter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	NewCustomLogger(buf, &CustomHandlerOptions{}).Error("failed", "err", stackError{})
+	if !strings.Contains(buf.String(), "- err : stack error\n\tmain.go:12") {
+		t.Errorf("expected the error rendered with %%+v :\n%s", buf.String())
+	}
+}
+
+func TestGroupAttributes(t *testing.T) {
+	server, serverURL := customslogloggertest.NewCaptureServer()
+	defer server.Close()
+	buf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{JsonLogURL: serverURL}).WithGroup("req")
+
+	user := slog.Group("user",
+		slog.String("name", "x"),
+		slog.Group("address", slog.String("city", "Paris"), slog.Int("zip", 75001)),
+		slog.Group("empty"),
+	)
+	logger.Info("grouped", user, slog.Group("", slog.String("inlined", "yes")))
+
+	expected := "\n\t- req.user :\n\t\t- name : x\n\t\t- address :\n\t\t\t- city : Paris\n\t\t\t- zip : 75001\n\t- req.inlined : yes"
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("text output doesn't contain the indented groups :\n%s", buf.String())
+	}
+
+	payloads := server.Payloads()
+	if len(payloads) != 1 {
+		t.Fatalf("expected 1 json payload, got %d", len(payloads))
+	}
+	expectedJson := map[string]any{
+		"user": map[string]any{
+			"name":    "x",
+			"address": map[string]any{"city": "Paris", "zip": 75001.0},
+		},
+		"inlined": "yes",
+	}
+	if !reflect.DeepEqual(payloads[0]["req"], expectedJson) {
+		t.Errorf("unexpected json groups %v, expected %v", payloads[0]["req"], expectedJson)
+	}
+
+	buf.Reset()
+	NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact}).Info("compact", user)
+	if !strings.HasSuffix(buf.String(), " user.name=x user.address.city=Paris user.address.zip=75001\n") {
+		t.Errorf("unexpected compact groups %q", buf.String())
+	}
+}
+
+func TestTimeAndDurationValues(t *testing.T) {
+	at := time.Date(2024, 5, 17, 14, 30, 0, 500, time.FixedZone("CEST", 2*3600))
+	elapsed := 1500 * time.Millisecond
+
+	buf := &bytes.Buffer{}
+	jsonBuf := &bytes.Buffer{}
+	NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, JsonWriter: jsonBuf}).
+		Info("timed", "at", at, "now", time.Now(), "elapsed", elapsed)
+	if !strings.Contains(buf.String(), " at=2024-05-17T14:30:00+02:00 ") || !strings.Contains(buf.String(), " elapsed=1.5s") {
+		t.Errorf("expected the RFC 3339 time and the duration, got %q", buf.String())
+	}
+	if strings.Contains(buf.String(), "m=+") {
+		t.Errorf("expected no monotonic clock reading, got %q", buf.String())
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(jsonBuf.Bytes(), &payload); err != nil {
+		t.Fatalf("invalid json log : %s", err)
+	}
+	if payload["at"] != "2024-05-17T14:30:00.0000005+02:00" || payload["elapsed"] != "1.5s" {
+		t.Errorf("unexpected json time and duration %v %v", payload["at"], payload["elapsed"])
+	}
+
+	buf.Reset()
+	jsonBuf.Reset()
+	NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, TimeLayout: time.Kitchen, JsonWriter: jsonBuf, JsonDurationNanos: true}).
+		Info("timed", "at", at, "elapsed", elapsed)
+	if !strings.Contains(buf.String(), " at=2:30PM ") {
+		t.Errorf("expected the time with the custom layout, got %q", buf.String())
+	}
+	payload = nil
+	if err := json.Unmarshal(jsonBuf.Bytes(), &payload); err != nil {
+		t.Fatalf("invalid json log : %s", err)
+	}
+	if payload["elapsed"] != float64(elapsed.Nanoseconds()) {
+		t.Errorf("expected the duration in nanoseconds, got %v", payload["elapsed"])
+	}
+}
+
+// countedValue is a struct counting its json marshallings
+type countedValue struct {
+	n *int
+}
+
+func (c countedValue) MarshalJSON() ([]byte, error) {
+	*c.n++
+	return []byte(fmt.Sprintf(`{"n":%d}`, *c.n)), nil
+}
+
+func TestCompositeValues(t *testing.T) {
+	type address struct {
+		City string `json:"city"`
+		Zip  string `json:"zip"`
+	}
+	type unmarshallable struct {
+		Done chan bool
+	}
+
+	buf := &bytes.Buffer{}
+	jsonBuf := &bytes.Buffer{}
+	NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, JsonWriter: jsonBuf}).
+		Info("composite",
+			slog.Any("ids", []int{1, 2, 3}),
+			slog.Any("tags", map[string]int{"a": 1}),
+			slog.Any("address", address{City: "Lyon", Zip: "69001"}),
+			slog.Any("pointer", &address{City: "Paris"}),
+			slog.Any("channel", unmarshallable{}))
+	if !strings.Contains(buf.String(), `ids="[1 2 3]"`) {
+		t.Errorf("expected the text log to be unchanged, got %q", buf.String())
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(jsonBuf.Bytes(), &payload); err != nil {
+		t.Fatalf("invalid json log : %s", err)
+	}
+	for key, expected := range map[string]interface{}{
+		"ids":     []interface{}{float64(1), float64(2), float64(3)},
+		"tags":    map[string]interface{}{"a": float64(1)},
+		"address": map[string]interface{}{"city": "Lyon", "zip": "69001"},
+		"pointer": map[string]interface{}{"city": "Paris", "zip": ""},
+		"channel": "{<nil>}",
+	} {
+		if !reflect.DeepEqual(payload[key], expected) {
+			t.Errorf("expected the json value %s = %#v, got %#v", key, expected, payload[key])
+		}
+	}
+
+	//each composite value is marshalled once
+	marshalled := 0
+	jsonBuf.Reset()
+	NewCustomLogger(io.Discard, &CustomHandlerOptions{JsonWriter: jsonBuf}).Info("composite", "counted", countedValue{&marshalled})
+	if marshalled != 1 || !strings.Contains(jsonBuf.String(), `"counted":{"n":1}`) {
+		t.Errorf("expected the composite value to be marshalled once, got %d times : %s", marshalled, jsonBuf.String())
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	server, serverURL := customslogloggertest.NewCaptureServer()
+	defer server.Close()
+	buf := &bytes.Buffer{}
+	//a strict encoder rejecting the invalid UTF-8 strings
+	strict := func(v any) ([]byte, error) {
+		if strings.Contains(fmt.Sprint(v), "\xff") {
+			return nil, errors.New("invalid UTF-8")
+		}
+		return json.Marshal(v)
+	}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, JsonLogURL: serverURL, JsonMarshal: strict})
+	raw := []byte{'f', 'r', 0xff, 0xfe, 'm', 'e'}
+	logger.Info("frame \xff received", "frame", string(raw), "bytes", raw)
+
+	if strings.Contains(buf.String(), "\xff") || !strings.Contains(buf.String(), "frame=fr�me") {
+		t.Errorf("expected the invalid UTF-8 to be replaced in the text log, got %q", buf.String())
+	}
+	bodies := server.Bodies()
+	if len(bodies) != 1 {
+		t.Fatalf("expected the json log to be delivered, got %d", len(bodies))
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(bodies[0], &payload); err != nil {
+		t.Fatalf("invalid json log : %s", err)
+	}
+	if payload["msg"] != "frame � received" || payload["frame"] != "fr�me" || payload["bytes"] != "ZnL//m1l" {
+		t.Errorf("unexpected json log %v", payload)
+	}
+}
@@ -0,0 +1,72 @@
+package customsloglogger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"unicode"
+)
+
+// WriterOptions are the options of the io.Writer returned by WriterWithOptions()
+type WriterOptions struct {
+	//ParseLevelPrefix causes a leading level prefix of the messages ("ERROR:", "WARN:", "WARNING:",
+	//"INFO:", "DEBUG:", "PANIC:", case insensitive) to be stripped and used as the level of their log,
+	//e.g. for the legacy log.Print("ERROR: connection failed") calls
+	ParseLevelPrefix bool
+}
+
+// logWriter is an io.Writer logging each written []byte as a message
+type logWriter struct {
+	logger  *CustomLogger
+	level   slog.Level
+	options WriterOptions
+}
+
+// Write() logs p as a message at the level of the logWriter (or of its level prefix
+// with the ParseLevelPrefix option), without its trailing newline
+func (w *logWriter) Write(p []byte) (int, error) {
+	msg, level := strings.TrimSuffix(string(p), "\n"), w.level
+	if w.options.ParseLevelPrefix {
+		if prefixLevel, rest, ok := cutLevelPrefix(msg); ok {
+			msg, level = rest, prefixLevel
+		}
+	}
+	w.logger.Log(context.Background(), level, msg)
+	return len(p), nil
+}
+
+// cutLevelPrefix(msg) returns the level of the leading "LEVEL:" prefix of the message
+// and the message without it, or false if the message has no level prefix
+func cutLevelPrefix(msg string) (slog.Level, string, bool) {
+	prefix, rest, found := strings.Cut(msg, ":")
+	if !found || prefix == "" || strings.ContainsFunc(prefix, func(r rune) bool { return !unicode.IsLetter(r) }) {
+		return 0, msg, false
+	}
+	if strings.EqualFold(prefix, "warning") {
+		return slog.LevelWarn, strings.TrimLeft(rest, " "), true
+	}
+	level, err := ParseLevel(prefix)
+	if err != nil {
+		return 0, msg, false
+	}
+	return level, strings.TrimLeft(rest, " "), true
+}
+
+// Writer() returns an io.Writer logging each write as a message with the given level.
+// It can be used to redirect the standard library log package in the CustomLogger :
+//
+//	log.SetOutput(logger.Writer(slog.LevelInfo))
+//	log.SetFlags(0)
+func (c *CustomLogger) Writer(level slog.Level) io.Writer {
+	return &logWriter{logger: c, level: level}
+}
+
+// WriterWithOptions() returns an io.Writer logging each write as a message with the given level,
+// as Writer(), with the WriterOptions, e.g. to log the legacy "ERROR: ..." messages at the Error level :
+//
+//	log.SetOutput(logger.WriterWithOptions(slog.LevelInfo, customsloglogger.WriterOptions{ParseLevelPrefix: true}))
+//	log.SetFlags(0)
+func (c *CustomLogger) WriterWithOptions(level slog.Level, options WriterOptions) io.Writer {
+	return &logWriter{logger: c, level: level, options: options}
+}
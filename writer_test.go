@@ -0,0 +1,66 @@
+package customsloglogger
+
+import (
+	"bytes"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, AddSource: true})
+
+	stdLogger := log.New(logger.Writer(slog.LevelWarn), "", 0)
+	stdLogger.Print("legacy message")
+
+	output := buf.String()
+	if !strings.HasPrefix(output, "WARN ") {
+		t.Errorf("expected a WARN log, got %q", output)
+	}
+	if !strings.HasSuffix(output, " legacy message\n") {
+		t.Errorf("expected the message without its trailing newline, got %q", output)
+	}
+	if !strings.Contains(output, "@writer_test.go:") {
+		t.Errorf("expected the source of the std log call, got %q", output)
+	}
+
+	buf.Reset()
+	log.New(logger.Writer(slog.LevelDebug), "", 0).Print("filtered message")
+	if buf.Len() != 0 {
+		t.Errorf("expected a Debug log to be filtered, got %q", buf.String())
+	}
+}
+
+func TestWriterParseLevelPrefix(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewCustomLogger(buf, &CustomHandlerOptions{Format: FormatCompact, MinimumLevel: slog.LevelDebug})
+	stdLogger := log.New(logger.WriterWithOptions(slog.LevelInfo, WriterOptions{ParseLevelPrefix: true}), "", 0)
+
+	for _, test := range []struct {
+		message string
+		level   string
+		logged  string
+	}{
+		{"ERROR: connection failed", "ERROR", "connection failed"},
+		{"WARN: disk almost full", "WARN", "disk almost full"},
+		{"warning: deprecated call", "WARN", "deprecated call"},
+		{"debug:cache miss", "DEBUG", "cache miss"},
+		{"server started", "INFO", "server started"},
+		{"note: not a level", "INFO", "note: not a level"},
+		{"at 10:30 : started", "INFO", "at 10:30 : started"},
+	} {
+		buf.Reset()
+		stdLogger.Print(test.message)
+		if output := buf.String(); !strings.HasPrefix(output, test.level+" ") || !strings.HasSuffix(output, " "+test.logged+"\n") {
+			t.Errorf("expected %q logged as %s %q, got %q", test.message, test.level, test.logged, output)
+		}
+	}
+
+	buf.Reset()
+	log.New(logger.Writer(slog.LevelInfo), "", 0).Print("ERROR: not parsed")
+	if !strings.HasPrefix(buf.String(), "INFO ") || !strings.HasSuffix(buf.String(), " ERROR: not parsed\n") {
+		t.Errorf("expected the prefix to be kept without the option, got %q", buf.String())
+	}
+}